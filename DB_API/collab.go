@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// Collaboration over WebSocket: each contact has a room. Members announce
+// whether they are viewing or editing, one member at a time can hold an edit
+// lock lease, and changes committed through the REST API are pushed to the
+// room. Room state lives in this process only.
+//
+// Granting a lease sends its holder, and only its holder, a lock token.
+// Writes to a locked contact are refused unless they send that token back in
+// X-Lock-Token: user names are self-reported, so they cannot prove who holds
+// the lease.
+const (
+	collabLeaseTTL    = 30 * time.Second
+	collabSendBuffer  = 32
+	collabWriteWait   = 10 * time.Second
+	collabPongWait    = 60 * time.Second
+	collabPingPeriod  = collabPongWait * 9 / 10
+	collabMaxMsgBytes = 4096
+
+	modeViewing = "viewing"
+	modeEditing = "editing"
+
+	// headerUser is the caller's self-reported name, shown in presence and logs.
+	headerUser = "X-User"
+	// headerLockToken carries the token of the edit lock a write is made under.
+	headerLockToken = "X-Lock-Token"
+)
+
+// collabMessage is the envelope for both directions. Clients send
+// "mode", "lock", "renew" and "unlock"; the server sends "welcome",
+// "presence", "lock", "error" and the contact.* event types.
+type collabMessage struct {
+	Type    string         `json:"type"`
+	Mode    string         `json:"mode,omitempty"`
+	ConnID  int64          `json:"connId,omitempty"`
+	Members []collabMember `json:"members,omitempty"`
+	Lock    *editLock      `json:"lock,omitempty"`
+	Token   string         `json:"token,omitempty"`
+	Event   *Event         `json:"event,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type collabMember struct {
+	ConnID int64     `json:"connId"`
+	User   string    `json:"user"`
+	Mode   string    `json:"mode"`
+	Since  time.Time `json:"since"`
+}
+
+// editLock is a lease on editing a contact. It lapses at ExpiresAt unless renewed.
+type editLock struct {
+	Holder    string    `json:"holder"`
+	ConnID    int64     `json:"connId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	token string // sent to the holder only
+}
+
+type collabConn struct {
+	id      int64
+	user    string
+	version string // API version of the upgrade request; events are sent in it
+	mode    string
+	since   time.Time
+	ws      *websocket.Conn
+	send    chan collabMessage
+	closed  bool
+}
+
+type collabRoom struct {
+	contactID int64
+	members   map[*collabConn]struct{}
+	lock      *editLock
+}
+
+type collabHub struct {
+	mu     sync.Mutex
+	rooms  map[int64]*collabRoom
+	nextID atomic.Int64
+}
+
+var collab = &collabHub{rooms: make(map[int64]*collabRoom)}
+
+var errLockHeld = errors.New("contact is locked for editing by another user")
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkWSOrigin,
+}
+
+// checkWSOrigin allows same-origin requests plus websocket.allowedOrigins
+// (WS_ALLOWED_ORIGINS, comma-separated, "*" for any).
+func checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range cfg().WebSocket.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return strings.EqualFold(strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://"), r.Host)
+}
+
+// contactLive upgrades GET /contacts/{id}/live?user=<name> to a WebSocket
+// joined to that contact's room.
+func contactLive(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	user := strings.TrimSpace(r.URL.Query().Get("user"))
+	if user == "" {
+		user = strings.TrimSpace(r.Header.Get(headerUser))
+	}
+	if user == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("user is required"))
+		return
+	}
+	var exists bool
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = ?)`, id).Scan(&exists); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+		return
+	}
+
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader already wrote an HTTP error
+	}
+	// The request context ends when the server shuts down (endOnShutdown).
+	stop := context.AfterFunc(r.Context(), func() {
+		_ = ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(collabWriteWait))
+		ws.Close()
+	})
+	defer stop()
+	c := &collabConn{
+		id:      collab.nextID.Add(1),
+		user:    user,
+		version: apiVersionOf(r),
+		mode:    modeViewing,
+		since:   time.Now().UTC(),
+		ws:      ws,
+		send:    make(chan collabMessage, collabSendBuffer),
+	}
+	go c.writePump()
+	collab.join(id, c)
+	defer collab.leave(id, c)
+	c.readPump(id)
+}
+
+func (c *collabConn) readPump(contactID int64) {
+	c.ws.SetReadLimit(collabMaxMsgBytes)
+	_ = c.ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+	for {
+		var msg collabMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Warn("collab: read", "contact_id", contactID, "conn", c.id, "err", err)
+			}
+			return
+		}
+		collab.handle(contactID, c, msg)
+	}
+}
+
+func (c *collabConn) writePump() {
+	ping := time.NewTicker(collabPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.ws.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if !ok {
+				_ = c.ws.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if msg.Event != nil {
+				evt := renderEvent(c.version, *msg.Event)
+				msg.Event = &evt
+			}
+			if err := c.ws.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// deliver queues msg for c without blocking; a connection that cannot keep
+// up is closed. Callers hold h.mu.
+func (h *collabHub) deliver(c *collabConn, msg collabMessage) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.closed = true
+		close(c.send)
+	}
+}
+
+func (h *collabHub) broadcast(room *collabRoom, msg collabMessage) {
+	for c := range room.members {
+		h.deliver(c, msg)
+	}
+}
+
+func (h *collabHub) join(contactID int64, c *collabConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[contactID]
+	if !ok {
+		room = &collabRoom{contactID: contactID, members: make(map[*collabConn]struct{})}
+		h.rooms[contactID] = room
+	}
+	room.members[c] = struct{}{}
+	h.deliver(c, collabMessage{Type: "welcome", ConnID: c.id, Lock: room.activeLock()})
+	h.broadcast(room, collabMessage{Type: "presence", Members: room.presence()})
+}
+
+func (h *collabHub) leave(contactID int64, c *collabConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[contactID]
+	if !ok {
+		return
+	}
+	delete(room.members, c)
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+	if room.lock != nil && room.lock.ConnID == c.id {
+		room.lock = nil
+		h.broadcast(room, collabMessage{Type: "lock"})
+	}
+	if len(room.members) == 0 {
+		delete(h.rooms, contactID)
+		return
+	}
+	h.broadcast(room, collabMessage{Type: "presence", Members: room.presence()})
+}
+
+func (h *collabHub) handle(contactID int64, c *collabConn, msg collabMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[contactID]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	switch msg.Type {
+	case "mode":
+		if msg.Mode != modeViewing && msg.Mode != modeEditing {
+			h.deliver(c, collabMessage{Type: "error", Error: fmt.Sprintf("invalid mode %q", msg.Mode)})
+			return
+		}
+		c.mode = msg.Mode
+		h.broadcast(room, collabMessage{Type: "presence", Members: room.presence()})
+	case "lock", "renew":
+		if l := room.activeLock(); l != nil && l.ConnID != c.id {
+			h.deliver(c, collabMessage{Type: "error", Error: errLockHeld.Error(), Lock: l})
+			return
+		}
+		if msg.Type == "renew" && room.activeLock() == nil {
+			h.deliver(c, collabMessage{Type: "error", Error: "no lock to renew"})
+			return
+		}
+		token := newEventID()
+		if l := room.activeLock(); l != nil {
+			token = l.token // renewing keeps the token
+		}
+		room.lock = &editLock{Holder: c.user, ConnID: c.id, ExpiresAt: now.Add(collabLeaseTTL), token: token}
+		c.mode = modeEditing
+		for m := range room.members {
+			msg := collabMessage{Type: "lock", Lock: room.lock}
+			if m == c {
+				msg.Token = token
+			}
+			h.deliver(m, msg)
+		}
+		h.broadcast(room, collabMessage{Type: "presence", Members: room.presence()})
+	case "unlock":
+		if room.lock == nil || room.lock.ConnID != c.id {
+			h.deliver(c, collabMessage{Type: "error", Error: "lock not held"})
+			return
+		}
+		room.lock = nil
+		h.broadcast(room, collabMessage{Type: "lock"})
+	default:
+		h.deliver(c, collabMessage{Type: "error", Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// lockedByOther reports whether contactID has a live lease that token, the
+// lock token sent with a write, is not for. Write handlers use it to refuse
+// conflicting edits.
+func (h *collabHub) lockedByOther(contactID int64, token string) (*editLock, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[contactID]
+	if !ok {
+		return nil, false
+	}
+	l := room.activeLock()
+	if l == nil || (token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(l.token)) == 1) {
+		return nil, false
+	}
+	return l, true
+}
+
+// run expires lapsed leases and forwards committed contact events to the
+// matching room until ctx is cancelled.
+func (h *collabHub) run(ctx context.Context) {
+	sub, _, _ := eventHub.subscribe(0, eventFilter{})
+	defer func() { eventHub.unsubscribe(sub) }()
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.ch:
+			if !ok {
+				// Dropped for falling behind; subscribe again.
+				sub, _, _ = eventHub.subscribe(0, eventFilter{})
+				continue
+			}
+			h.forward(e)
+		case <-t.C:
+			h.expireLeases()
+		}
+	}
+}
+
+func (h *collabHub) forward(e hubEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[e.evt.ContactID]
+	if !ok {
+		return
+	}
+	evt := e.evt
+	h.broadcast(room, collabMessage{Type: evt.Type, Event: &evt})
+	if evt.Type == eventContactDeleted {
+		room.lock = nil
+		h.broadcast(room, collabMessage{Type: "lock"})
+	}
+}
+
+func (h *collabHub) expireLeases() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, room := range h.rooms {
+		if room.lock != nil && room.activeLock() == nil {
+			room.lock = nil
+			h.broadcast(room, collabMessage{Type: "lock"})
+		}
+	}
+}
+
+func (room *collabRoom) activeLock() *editLock {
+	if room.lock == nil || time.Now().After(room.lock.ExpiresAt) {
+		return nil
+	}
+	return room.lock
+}
+
+func (room *collabRoom) presence() []collabMember {
+	out := make([]collabMember, 0, len(room.members))
+	for c := range room.members {
+		out = append(out, collabMember{ConnID: c.id, User: c.user, Mode: c.mode, Since: c.since})
+	}
+	slices.SortFunc(out, func(a, b collabMember) int { return int(a.ConnID - b.ConnID) })
+	return out
+}
+
+// rejectIfLocked writes 423 Locked when the contact has an edit lease and the
+// request does not carry its token in X-Lock-Token.
+func rejectIfLocked(w http.ResponseWriter, r *http.Request, id int64) bool {
+	l, locked := collab.lockedByOther(id, r.Header.Get(headerLockToken))
+	if !locked {
+		return false
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(int(time.Until(l.ExpiresAt).Seconds())+1))
+	writeJSON(w, http.StatusLocked, map[string]any{
+		"error":   errLockHeld.Error(),
+		"lock":    l,
+		"traceId": w.Header().Get(traceIDHeader),
+	})
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func joinTestRoom(h *collabHub, contactID int64, user string) *collabConn {
+	c := &collabConn{id: h.nextID.Add(1), user: user, mode: modeViewing, send: make(chan collabMessage, collabSendBuffer)}
+	h.join(contactID, c)
+	return c
+}
+
+// lastLock drains c's queue and returns the last "lock" message in it.
+func lastLock(c *collabConn) (msg collabMessage, ok bool) {
+	for {
+		select {
+		case m := <-c.send:
+			if m.Type == "lock" {
+				msg, ok = m, true
+			}
+		default:
+			return msg, ok
+		}
+	}
+}
+
+func TestEditLockToken(t *testing.T) {
+	old := collab
+	collab = &collabHub{rooms: make(map[int64]*collabRoom)}
+	t.Cleanup(func() { collab = old })
+	const id = 7
+	grace := joinTestRoom(collab, id, "grace")
+	ada := joinTestRoom(collab, id, "ada")
+
+	collab.handle(id, grace, collabMessage{Type: "lock"})
+	granted, ok := lastLock(grace)
+	if !ok || granted.Token == "" || granted.Lock == nil || granted.Lock.Holder != "grace" {
+		t.Fatalf("holder got %+v, want the lock with a token", granted)
+	}
+	if seen, ok := lastLock(ada); !ok || seen.Lock == nil || seen.Token != "" {
+		t.Errorf("other member got %+v, want the lock without its token", seen)
+	}
+
+	collab.handle(id, grace, collabMessage{Type: "renew"})
+	if renewed, _ := lastLock(grace); renewed.Token != granted.Token {
+		t.Errorf("renewing changed the token from %q to %q", granted.Token, renewed.Token)
+	}
+
+	write := func(header, value string) int {
+		r := httptest.NewRequest(http.MethodPatch, "/contacts/7", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		if !rejectIfLocked(w, r, id) {
+			return http.StatusOK
+		}
+		return w.Code
+	}
+	for _, tt := range []struct {
+		name, header, value string
+		want                int
+	}{
+		{"no token", "", "", http.StatusLocked},
+		{"claims to be the holder", headerUser, "grace", http.StatusLocked},
+		{"wrong token", headerLockToken, granted.Token + "0", http.StatusLocked},
+		{"lock token", headerLockToken, granted.Token, http.StatusOK},
+	} {
+		if got := write(tt.header, tt.value); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	collab.handle(id, grace, collabMessage{Type: "unlock"})
+	if got := write("", ""); got != http.StatusOK {
+		t.Errorf("after unlock: status %d, want %d", got, http.StatusOK)
+	}
+}
@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration is layered. Each source overrides the one before it:
+//
+//  1. the defaults in defaultConfig
+//  2. a YAML or TOML file named by -config or CONFIG_FILE
+//  3. environment variables (the env tags below; an oldEnv tag names a
+//     deprecated variable still read when the current one is unset)
+//  4. command-line flags, named after the file keys: -db.maxOpenConns=20
+//
+// Keys in TOML files are the same as in YAML ([db] maxOpenConns = 20).
+// Lists are comma-separated and maps "key=value; key=value" in env vars and
+// flags. SIGHUP re-reads the file and environment and applies the settings
+// tagged reload; the rest (listeners, connections, workers) are fixed at
+// startup and changing them is logged as needing a restart.
+type Config struct {
+	HTTP       HTTPConfig       `yaml:"http"`
+	GRPC       GRPCConfig       `yaml:"grpc"`
+	DB         DBConfig         `yaml:"db"`
+	Health     HealthConfig     `yaml:"health"`
+	Pagination PaginationConfig `yaml:"pagination"`
+	RateLimit  RateLimitConfig  `yaml:"rateLimit"`
+	OpenAPI    OpenAPIConfig    `yaml:"openapi"`
+	API        APIConfig        `yaml:"api"`
+	GraphQL    GraphQLConfig    `yaml:"graphql"`
+	WebSocket  WebSocketConfig  `yaml:"websocket"`
+	Duplicates DuplicatesConfig `yaml:"duplicates"`
+	Reminders  RemindersConfig  `yaml:"reminders"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Log        LogConfig        `yaml:"log"`
+}
+
+// HTTPConfig sets the listener and the http.Server limits. WriteTimeout
+// bounds ordinary responses; streams clear it for themselves. RequestTimeout
+// is the deadline handlers get for their work, short of WriteTimeout so
+// that a timeout can still be reported; streams are exempt. Shutdown fails
+// readiness, waits DrainDelay for load balancers to notice, then drains
+// in-flight requests within ShutdownTimeout.
+type HTTPConfig struct {
+	Addr              string        `yaml:"addr" env:"HTTP_ADDR"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	RequestTimeout    time.Duration `yaml:"requestTimeout" env:"HTTP_REQUEST_TIMEOUT" reload:"true"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" reload:"true"`
+	DrainDelay        time.Duration `yaml:"drainDelay" env:"SHUTDOWN_DRAIN_DELAY" reload:"true"`
+}
+
+// GRPCConfig sets the ContactService listener; "off" disables it.
+type GRPCConfig struct {
+	Addr string `yaml:"addr" env:"GRPC_ADDR"`
+}
+
+// DBConfig.Driver is "mysql" or "postgres" (see postgres.go); DSN is in that
+// driver's format. StartupTimeout is how long startup waits for the database
+// to answer; 0 waits until the process is stopped. QueryTimeout bounds each
+// storage call, a query or a whole transaction; 0 leaves only the request's
+// deadline. StmtCacheSize is how many run-time built queries keep a prepared
+// statement (see repository.go); 0 prepares only the fixed ones. Replicas,
+// ReplicaCheckInterval and ReadYourWrites are described in replicas.go.
+type DBConfig struct {
+	Driver          string        `yaml:"driver" env:"DB_DRIVER"`
+	DSN             string        `yaml:"dsn" env:"DB_DSN" oldEnv:"MYSQL_DSN" secret:"dsn"`
+	StartupTimeout  time.Duration `yaml:"startupTimeout" env:"DB_STARTUP_TIMEOUT"`
+	QueryTimeout    time.Duration `yaml:"queryTimeout" env:"DB_QUERY_TIMEOUT" reload:"true"`
+	MaxOpenConns    int           `yaml:"maxOpenConns" env:"DB_MAX_OPEN_CONNS" reload:"true"`
+	MaxIdleConns    int           `yaml:"maxIdleConns" env:"DB_MAX_IDLE_CONNS" reload:"true"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME" reload:"true"`
+	StmtCacheSize   int           `yaml:"stmtCacheSize" env:"DB_STMT_CACHE_SIZE"`
+
+	Replicas             []string      `yaml:"replicas" env:"DB_REPLICA_DSNS" secret:"dsn"`
+	ReplicaCheckInterval time.Duration `yaml:"replicaCheckInterval" env:"DB_REPLICA_CHECK_INTERVAL"`
+	ReadYourWrites       time.Duration `yaml:"readYourWrites" env:"DB_READ_YOUR_WRITES" reload:"true"`
+}
+
+// name is the database named in the DSN, used to label pool metrics.
+func (c DBConfig) name() string {
+	if c.Driver == "postgres" {
+		if conf, err := pgx.ParseConfig(c.DSN); err == nil && conf.Database != "" {
+			return conf.Database
+		}
+		return c.Driver
+	}
+	if dsn, err := mysql.ParseDSN(c.DSN); err == nil && dsn.DBName != "" {
+		return dsn.DBName
+	}
+	return c.Driver
+}
+
+// HealthConfig.CheckTimeout bounds each check behind /healthz and /readyz.
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"checkTimeout" env:"HEALTH_CHECK_TIMEOUT" reload:"true"`
+}
+
+// PaginationConfig bounds pageSize (and limit) on list endpoints; values
+// outside 1..MaxPageSize fall back to DefaultPageSize.
+type PaginationConfig struct {
+	DefaultPageSize int `yaml:"defaultPageSize" env:"DEFAULT_PAGE_SIZE" reload:"true"`
+	MaxPageSize     int `yaml:"maxPageSize" env:"MAX_PAGE_SIZE" reload:"true"`
+}
+
+// RateLimitConfig is described in ratelimit.go. Routes maps a route to a
+// policy or "off"; By lists the identities to key buckets by, in order of
+// preference; APIKeys maps the SHA-256 of each known API key to its client.
+type RateLimitConfig struct {
+	Default  string            `yaml:"default" env:"RATE_LIMIT" reload:"true"`
+	Routes   map[string]string `yaml:"routes" env:"RATE_LIMIT_ROUTES" reload:"true"`
+	By       []string          `yaml:"by" env:"RATE_LIMIT_BY" reload:"true"`
+	APIKeys  map[string]string `yaml:"apiKeys" env:"RATE_LIMIT_API_KEYS" reload:"true"`
+	Backend  string            `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	RedisURL string            `yaml:"redisURL" env:"REDIS_URL" secret:"url"`
+
+	rules *rateRules // compiled by validate
+}
+
+// OpenAPIConfig.Validation is off, requests, responses or strict.
+type OpenAPIConfig struct {
+	Validation string `yaml:"validation" env:"OPENAPI_VALIDATION" reload:"true"`
+}
+
+// APIConfig.V1Deprecated is when v1 was deprecated, announced in the
+// Deprecation header; empty means it is not. V1Sunset announces when it goes
+// away, e.g. 2027-06-30, and cannot come before the deprecation.
+type APIConfig struct {
+	V1Deprecated string `yaml:"v1Deprecated" env:"API_V1_DEPRECATED" reload:"true"`
+	V1Sunset     string `yaml:"v1Sunset" env:"API_V1_SUNSET" reload:"true"`
+
+	v1Deprecated, v1Sunset time.Time // parsed by validate
+}
+
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"maxDepth" env:"GRAPHQL_MAX_DEPTH"`
+	MaxComplexity int `yaml:"maxComplexity" env:"GRAPHQL_MAX_COMPLEXITY" reload:"true"`
+}
+
+// WebSocketConfig.AllowedOrigins are accepted besides same-origin; "*" allows any.
+type WebSocketConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" env:"WS_ALLOWED_ORIGINS" reload:"true"`
+}
+
+// DuplicatesConfig.ScanInterval is how often the background scan runs; 0 disables it.
+type DuplicatesConfig struct {
+	ScanInterval time.Duration `yaml:"scanInterval" env:"DUPLICATE_SCAN_INTERVAL"`
+}
+
+// RemindersConfig picks the notifier for due reminders; see notifiers.go.
+type RemindersConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval" env:"REMINDER_POLL_INTERVAL"`
+	Notifier     string        `yaml:"notifier" env:"REMINDER_NOTIFIER"`
+	WebhookURL   string        `yaml:"webhookURL" env:"REMINDER_WEBHOOK_URL" secret:"url"`
+	SMTPAddr     string        `yaml:"smtpAddr" env:"REMINDER_SMTP_ADDR"`
+	EmailFrom    string        `yaml:"emailFrom" env:"REMINDER_EMAIL_FROM"`
+	EmailTo      string        `yaml:"emailTo" env:"REMINDER_EMAIL_TO"`
+}
+
+// OutboxConfig lists the sinks contact events are relayed to; see outbox_sinks.go.
+// WebhookAllowPrivate lets webhook subscriptions reach loopback, link-local
+// and private addresses. It is off so that a subscriber cannot point the
+// server at internal services; turn it on for local development.
+type OutboxConfig struct {
+	Sinks               []string `yaml:"sinks" env:"OUTBOX_SINKS"`
+	NATSURL             string   `yaml:"natsURL" env:"NATS_URL" secret:"url"`
+	NATSSubjectPrefix   string   `yaml:"natsSubjectPrefix" env:"NATS_SUBJECT_PREFIX"`
+	WebhookAllowPrivate bool     `yaml:"webhookAllowPrivate" env:"WEBHOOK_ALLOW_PRIVATE" reload:"true"`
+}
+
+// TracingConfig uses the standard OpenTelemetry variable names. Endpoint
+// defaults to a collector on localhost for the protocol.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Protocol    string  `yaml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
+	ServiceName string  `yaml:"serviceName" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sampleRatio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+func (c TracingConfig) endpoint() string {
+	switch {
+	case c.Endpoint != "":
+		return c.Endpoint
+	case c.Protocol == "grpc":
+		return "http://localhost:4317"
+	}
+	return "http://localhost:4318"
+}
+
+// LogConfig is described in logging.go. Sample maps a route, written as in
+// rateLimit.routes, to the share of its successful requests that are logged.
+type LogConfig struct {
+	Level     string            `yaml:"level" env:"LOG_LEVEL" reload:"true"`
+	Format    string            `yaml:"format" env:"LOG_FORMAT"`
+	Sample    map[string]string `yaml:"sample" env:"LOG_SAMPLE" reload:"true"`
+	RedactPII bool              `yaml:"redactPII" env:"LOG_REDACT_PII" reload:"true"`
+
+	level  slog.Level         // parsed by validate
+	sample map[string]float64 // parsed by validate
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			RequestTimeout:    25 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		GRPC: GRPCConfig{Addr: ":9090"},
+		DB: DBConfig{
+			Driver: "mysql",
+			// Safe default for local dev (adjust user/pass/db as needed)
+			DSN:             "root:RootRoot@tcp(127.0.0.1:3306)/contactsdb?parseTime=true&charset=utf8mb4",
+			StartupTimeout:  time.Minute,
+			QueryTimeout:    10 * time.Second,
+			MaxOpenConns:    10,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			StmtCacheSize:   128,
+
+			ReplicaCheckInterval: 5 * time.Second,
+			ReadYourWrites:       5 * time.Second,
+		},
+		Health:     HealthConfig{CheckTimeout: 2 * time.Second},
+		Pagination: PaginationConfig{DefaultPageSize: 50, MaxPageSize: 200},
+		RateLimit: RateLimitConfig{
+			By:       strings.Split(defaultRateLimitBy, ","),
+			Backend:  "memory",
+			RedisURL: "redis://localhost:6379/0",
+		},
+		OpenAPI: OpenAPIConfig{Validation: validateRequests},
+		API:     APIConfig{V1Deprecated: "2026-10-19"}, // when v2 shipped
+		GraphQL: GraphQLConfig{
+			MaxDepth:      defaultGraphQLMaxDepth,
+			MaxComplexity: defaultGraphQLMaxComplexity,
+		},
+		Duplicates: DuplicatesConfig{ScanInterval: time.Hour},
+		Reminders: RemindersConfig{
+			PollInterval: time.Minute,
+			Notifier:     "log",
+			SMTPAddr:     "localhost:1025", // e.g. MailHog
+			EmailFrom:    "reminders@localhost",
+		},
+		Outbox: OutboxConfig{
+			Sinks:             []string{"webhook"},
+			NATSURL:           nats.DefaultURL,
+			NATSSubjectPrefix: "contacts",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Protocol:    "http/protobuf",
+			ServiceName: "contacts-api",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{Level: "info", Format: "json"},
+	}
+}
+
+// currentConfig is the configuration in effect; SIGHUP swaps it.
+var currentConfig atomic.Pointer[Config]
+
+// cfg returns the configuration in effect. Code that reads reloadable
+// settings calls it each time rather than keeping a copy.
+func cfg() *Config { return currentConfig.Load() }
+
+// validate checks every setting, reporting all problems at once, and
+// compiles the ones that need parsing.
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.HTTP.Addr != "", "http.addr", "must not be empty")
+	for _, d := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"http.readHeaderTimeout", c.HTTP.ReadHeaderTimeout},
+		{"http.readTimeout", c.HTTP.ReadTimeout},
+		{"http.writeTimeout", c.HTTP.WriteTimeout},
+		{"http.idleTimeout", c.HTTP.IdleTimeout},
+		{"http.drainDelay", c.HTTP.DrainDelay},
+		{"http.requestTimeout", c.HTTP.RequestTimeout},
+		{"db.startupTimeout", c.DB.StartupTimeout},
+		{"db.queryTimeout", c.DB.QueryTimeout},
+		{"db.connMaxLifetime", c.DB.ConnMaxLifetime},
+		{"db.readYourWrites", c.DB.ReadYourWrites},
+		{"duplicates.scanInterval", c.Duplicates.ScanInterval},
+	} {
+		check(d.d >= 0, d.key, "must not be negative")
+	}
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdownTimeout", "must be positive")
+	check(c.HTTP.WriteTimeout == 0 || c.HTTP.RequestTimeout < c.HTTP.WriteTimeout,
+		"http.requestTimeout", "must be less than http.writeTimeout (%s)", c.HTTP.WriteTimeout)
+	check(c.GRPC.Addr != "", "grpc.addr", `must not be empty (use "off" to disable)`)
+
+	checkDSN := func(key, dsn string) {
+		var err error
+		switch c.DB.Driver {
+		case "mysql":
+			_, err = mysql.ParseDSN(dsn)
+		case "postgres":
+			_, err = pgx.ParseConfig(dsn)
+		}
+		check(err == nil, key, "%v", err)
+	}
+	switch c.DB.Driver {
+	case "mysql", "postgres":
+		checkDSN("db.dsn", c.DB.DSN)
+		for i, dsn := range c.DB.Replicas {
+			checkDSN(fmt.Sprintf("db.replicas[%d]", i), dsn)
+		}
+	default:
+		check(false, "db.driver", `must be "mysql" or "postgres", got %q`, c.DB.Driver)
+	}
+	check(c.DB.MaxOpenConns >= 0, "db.maxOpenConns", "must not be negative (0 is unlimited)")
+	check(c.DB.MaxIdleConns >= 0, "db.maxIdleConns", "must not be negative")
+	check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns,
+		"db.maxIdleConns", "must not exceed db.maxOpenConns (%d)", c.DB.MaxOpenConns)
+	check(c.DB.StmtCacheSize >= 0, "db.stmtCacheSize", "must not be negative")
+	check(c.DB.ReplicaCheckInterval > 0, "db.replicaCheckInterval", "must be positive")
+
+	check(c.Health.CheckTimeout > 0, "health.checkTimeout", "must be positive")
+
+	check(c.Pagination.MaxPageSize >= 1, "pagination.maxPageSize", "must be at least 1")
+	check(c.Pagination.DefaultPageSize >= 1 && c.Pagination.DefaultPageSize <= c.Pagination.MaxPageSize,
+		"pagination.defaultPageSize", "must be between 1 and pagination.maxPageSize (%d)", c.Pagination.MaxPageSize)
+
+	rules, err := parseRateRules(c.RateLimit)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	c.RateLimit.rules = rules
+	switch c.RateLimit.Backend {
+	case "memory":
+	case "redis":
+		if _, err := redis.ParseURL(c.RateLimit.RedisURL); err != nil {
+			check(false, "rateLimit.redisURL", "%v", err)
+		}
+	default:
+		check(false, "rateLimit.backend", "unknown backend %q (want memory or redis)", c.RateLimit.Backend)
+	}
+
+	switch c.OpenAPI.Validation {
+	case validateOff, validateRequests, validateResponses, validateStrict:
+	default:
+		check(false, "openapi.validation", "unknown mode %q (want off, requests, responses or strict)", c.OpenAPI.Validation)
+	}
+
+	parseDate := func(key, s string) time.Time {
+		if s == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(dateLayout, s)
+		check(err == nil, key, "invalid date %q (want YYYY-MM-DD)", s)
+		return t
+	}
+	c.API.v1Deprecated = parseDate("api.v1Deprecated", c.API.V1Deprecated)
+	c.API.v1Sunset = parseDate("api.v1Sunset", c.API.V1Sunset)
+	if !c.API.v1Sunset.IsZero() {
+		check(!c.API.v1Deprecated.IsZero(), "api.v1Sunset", "requires api.v1Deprecated")
+		check(!c.API.v1Sunset.Before(c.API.v1Deprecated), "api.v1Sunset",
+			"must not be before api.v1Deprecated (%s)", c.API.V1Deprecated)
+	}
+
+	check(c.GraphQL.MaxDepth >= 1, "graphql.maxDepth", "must be at least 1")
+	check(c.GraphQL.MaxComplexity >= 1, "graphql.maxComplexity", "must be at least 1")
+
+	check(c.Reminders.PollInterval > 0, "reminders.pollInterval", "must be positive")
+	switch c.Reminders.Notifier {
+	case "log":
+	case "webhook":
+		check(c.Reminders.WebhookURL != "", "reminders.webhookURL", "is required for the webhook notifier")
+	case "email":
+		check(c.Reminders.EmailTo != "", "reminders.emailTo", "is required for the email notifier")
+	default:
+		check(false, "reminders.notifier", "unknown notifier %q (want log, webhook or email)", c.Reminders.Notifier)
+	}
+
+	for _, s := range c.Outbox.Sinks {
+		check(s == "webhook" || s == "stdout" || s == "nats", "outbox.sinks", "unknown sink %q (want webhook, stdout or nats)", s)
+	}
+
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "otlp",
+		"tracing.exporter", "unknown exporter %q (want none or otlp)", c.Tracing.Exporter)
+	check(c.Tracing.Protocol == "grpc" || c.Tracing.Protocol == "http/protobuf",
+		"tracing.protocol", "unknown protocol %q (want grpc or http/protobuf)", c.Tracing.Protocol)
+	if c.Tracing.Endpoint != "" {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.endpoint", "want a URL such as http://localhost:4318, got %q", c.Tracing.Endpoint)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio", "must be between 0 and 1")
+
+	if err := c.Log.level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		check(false, "log.level", "unknown level %q (want debug, info, warn or error)", c.Log.Level)
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "unknown format %q (want json or text)", c.Log.Format)
+	c.Log.sample = make(map[string]float64, len(c.Log.Sample))
+	for route, v := range c.Log.Sample {
+		ratio, err := strconv.ParseFloat(v, 64)
+		check(err == nil && ratio >= 0 && ratio <= 1, "log.sample", "%s: want a ratio between 0 and 1, got %q", route, v)
+		c.Log.sample[normalizeRateRoute(route)] = ratio
+	}
+	return errors.Join(errs...)
+}
+
+// deprecated is when an API version was deprecated, zero if it is current.
+func (c *Config) deprecated(version string) time.Time {
+	if version == "v1" {
+		return c.API.v1Deprecated
+	}
+	return time.Time{}
+}
+
+// sunset is the announced removal date of an API version, zero if none.
+func (c *Config) sunset(version string) time.Time {
+	if version == "v1" {
+		return c.API.v1Sunset
+	}
+	return time.Time{}
+}
+
+// configField is one setting, found by walking Config.
+type configField struct {
+	key    string // dotted file key, also the flag name
+	env    string
+	oldEnv string // deprecated name for env
+	secret string // how to redact: "dsn", "url" or "true"
+	reload bool
+	value  reflect.Value
+}
+
+func configFields(c *Config) []configField {
+	var fields []configField
+	sections := reflect.ValueOf(c).Elem()
+	for i := range sections.NumField() {
+		section, sf := sections.Field(i), sections.Type().Field(i)
+		for j := range section.NumField() {
+			f := section.Type().Field(j)
+			if !f.IsExported() {
+				continue
+			}
+			fields = append(fields, configField{
+				key:    sf.Tag.Get("yaml") + "." + f.Tag.Get("yaml"),
+				env:    f.Tag.Get("env"),
+				oldEnv: f.Tag.Get("oldEnv"),
+				secret: f.Tag.Get("secret"),
+				reload: f.Tag.Get("reload") == "true",
+				value:  section.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set parses s, as given in an env var or flag, into the field.
+func (f configField) set(s string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case v.Kind() == reflect.Map:
+		m := make(map[string]string)
+		for _, entry := range strings.Split(s, ";") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("%q: want key=value", entry)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// redact masks the credentials in s, the value of a setting whose secret
+// tag is kind.
+func redact(kind, s string) string {
+	if kind == "" || s == "" {
+		return s
+	}
+	switch kind {
+	case "dsn":
+		if strings.Contains(s, "://") { // PostgreSQL URL
+			if u, err := url.Parse(s); err == nil {
+				return u.Redacted()
+			}
+		} else if dsn, err := mysql.ParseDSN(s); err == nil {
+			if dsn.Passwd != "" {
+				dsn.Passwd = "xxxxx"
+			}
+			return dsn.FormatDSN()
+		}
+	case "url":
+		if u, err := url.Parse(s); err == nil {
+			return u.Redacted()
+		}
+	}
+	return "[redacted]"
+}
+
+// configLoader remembers where the configuration came from so that SIGHUP
+// can load it again. Flags are parsed once; the file and environment are
+// re-read on every load.
+type configLoader struct {
+	file  string
+	flags map[string]string // key -> value given on the command line
+	print bool
+}
+
+func newConfigLoader(args []string) (*configLoader, error) {
+	l := &configLoader{file: os.Getenv("CONFIG_FILE"), flags: make(map[string]string)}
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.StringVar(&l.file, "config", l.file, "YAML or TOML config `file` (CONFIG_FILE)")
+	fs.BoolVar(&l.print, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	// Each flag is also set on a throwaway Config so that bad values are
+	// reported as flag errors.
+	for _, f := range configFields(defaultConfig()) {
+		fs.Func(f.key, "overrides "+f.env, func(s string) error {
+			if err := f.set(s); err != nil {
+				return err
+			}
+			l.flags[f.key] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return l, nil
+}
+
+// load builds and validates the configuration from all layers. It does not
+// log: it runs before the logger is set up, so it returns its warnings
+// (deprecated variables in use) for the caller to log.
+func (l *configLoader) load() (c *Config, warnings []string, err error) {
+	c = defaultConfig()
+	if l.file != "" {
+		if err := readConfigFile(l.file, c); err != nil {
+			return nil, nil, err
+		}
+	}
+	fields := configFields(c)
+	for _, f := range fields {
+		name := f.env
+		s := os.Getenv(name)
+		if s == "" && f.oldEnv != "" {
+			if s = os.Getenv(f.oldEnv); s != "" {
+				name = f.oldEnv
+				warnings = append(warnings, fmt.Sprintf("%s is deprecated; use %s", f.oldEnv, f.env))
+			}
+		}
+		if s != "" {
+			if err := f.set(s); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	for _, f := range fields {
+		if s, ok := l.flags[f.key]; ok {
+			if err := f.set(s); err != nil {
+				return nil, nil, fmt.Errorf("-%s: %w", f.key, err)
+			}
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return c, warnings, nil
+}
+
+// readConfigFile decodes a .yaml, .yml or .toml file over c. Unknown keys
+// are errors so that typos do not go unnoticed.
+func readConfigFile(path string, c *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		// TOML keys match the field names case-insensitively, so the YAML
+		// keys work unchanged.
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config file %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q (want .yaml, .yml or .toml)", path, ext)
+	}
+	return nil
+}
+
+// Redacted renders the configuration as YAML with passwords masked.
+func (c *Config) Redacted() string {
+	cp := *c
+	for _, f := range configFields(&cp) {
+		switch {
+		case f.secret == "":
+		case f.value.Kind() == reflect.Slice:
+			// A new slice, since cp shares its backing array with c.
+			list := make([]string, f.value.Len())
+			for i := range list {
+				list[i] = redact(f.secret, f.value.Index(i).String())
+			}
+			f.value.Set(reflect.ValueOf(list))
+		default:
+			f.value.SetString(redact(f.secret, f.value.String()))
+		}
+	}
+	b, err := yaml.Marshal(&cp)
+	if err != nil {
+		return fmt.Sprintf("(cannot render configuration: %v)", err)
+	}
+	return string(b)
+}
+
+// watchReloads reloads the configuration on SIGHUP until done is closed. An
+// invalid configuration is logged and the running one kept. Settings that
+// cannot change without a restart keep their running values; apply is
+// called with the old and new configuration to push the rest into
+// components that do not read cfg() themselves. Load warnings were
+// logged at startup; the environment does not change on SIGHUP.
+func (l *configLoader) watchReloads(done <-chan struct{}, apply func(old, c *Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+		}
+		l.reload(apply)
+	}
+}
+
+// reload is one SIGHUP: load, keep the running values of settings that need
+// a restart, then store the result and apply it.
+func (l *configLoader) reload(apply func(old, c *Config)) {
+	c, _, err := l.load()
+	if err != nil {
+		slog.Error("config reload: keeping the running configuration", "err", err)
+		return
+	}
+	old := cfg()
+	oldFields := configFields(old)
+	var changed []string
+	for i, f := range configFields(c) {
+		if reflect.DeepEqual(f.value.Interface(), oldFields[i].value.Interface()) {
+			continue
+		}
+		if !f.reload {
+			slog.Warn("config reload: changed; restart to apply", "key", f.key)
+			f.value.Set(oldFields[i].value)
+			continue
+		}
+		changed = append(changed, f.key)
+	}
+	currentConfig.Store(c)
+	apply(old, c)
+	if len(changed) == 0 {
+		slog.Info("config reload: no changes")
+	} else {
+		slog.Info("config reload: applied", "keys", changed)
+	}
+}
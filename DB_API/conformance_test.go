@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	openapi_types "github.com/oapi-codegen/runtime/types"
+
+	"my-go-api/client"
+)
+
+// TestBackendConformance runs the same contact lifecycle on every configured
+// backend, so that MySQL and PostgreSQL answer alike.
+func TestBackendConformance(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		input := func(first, email string) client.ContactInput {
+			return client.ContactInput{FirstName: first, LastName: "Lovelace", Email: openapi_types.Email(email), Company: ptr("Analytical")}
+		}
+
+		t.Run("crud", func(t *testing.T) {
+			var c Contact
+			if status := do(t, h, http.MethodPost, "/contacts", input("Ada", "ada@example.com"), &c); status != http.StatusCreated {
+				t.Fatalf("create: status %d", status)
+			}
+			path := fmt.Sprintf("/contacts/%d", c.ID)
+			var got Contact
+			if status := do(t, h, http.MethodGet, path, nil, &got); status != http.StatusOK {
+				t.Fatalf("get: status %d", status)
+			}
+			if got.Email != "ada@example.com" || got.Company == nil || *got.Company != "Analytical" || got.Phone != nil {
+				t.Errorf("get: %+v", got)
+			}
+			if got.CreatedAt.Location() != time.UTC || !got.CreatedAt.Equal(c.CreatedAt) {
+				t.Errorf("createdAt %v, want %v in UTC", got.CreatedAt, c.CreatedAt)
+			}
+			var page struct{ Items []Contact }
+			if status := do(t, h, http.MethodGet, "/contacts?search=LOVE&sort=company", nil, &page); status != http.StatusOK || len(page.Items) != 1 {
+				t.Errorf("list: status %d, %d items; want one match ignoring case", status, len(page.Items))
+			}
+			var put, patched Contact
+			if status := do(t, h, http.MethodPut, path, client.ContactInput{FirstName: "Ada", LastName: "King", Email: "ada@example.com"}, &put); status != http.StatusOK || put.LastName != "King" || put.Company != nil {
+				t.Errorf("put: status %d, %+v", status, put)
+			}
+			if status := do(t, h, http.MethodPatch, path, client.ContactPatch{Phone: ptr("555-0100")}, &patched); status != http.StatusOK || patched.Phone == nil || patched.LastName != "King" {
+				t.Errorf("patch: status %d, %+v", status, patched)
+			}
+			if status := do(t, h, http.MethodDelete, path, nil, nil); status != http.StatusNoContent {
+				t.Errorf("delete: status %d", status)
+			}
+			if status := do(t, h, http.MethodGet, path, nil, nil); status != http.StatusNotFound {
+				t.Errorf("get deleted: status %d", status)
+			}
+		})
+
+		t.Run("unique email", func(t *testing.T) {
+			createTestContact(t, h, "Grace", "grace@example.com")
+			other := createTestContact(t, h, "Hedy", "hedy@example.com")
+			path := fmt.Sprintf("/contacts/%d", other)
+			for _, tt := range []struct {
+				method, path string
+				body         any
+			}{
+				{http.MethodPost, "/contacts", input("Grace", "grace@example.com")},
+				{http.MethodPut, path, input("Hedy", "grace@example.com")},
+				{http.MethodPatch, path, client.ContactPatch{Email: ptr(openapi_types.Email("grace@example.com"))}},
+			} {
+				var body struct{ Error string }
+				if status := do(t, h, tt.method, tt.path, tt.body, &body); status != http.StatusConflict || body.Error != "email already exists" {
+					t.Errorf("%s %s: status %d %q, want 409", tt.method, tt.path, status, body.Error)
+				}
+			}
+
+			_, err := db.ExecContext(context.Background(), `INSERT INTO contacts (first_name, last_name, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+				"Grace", "Test", "grace@example.com", time.Now(), time.Now())
+			if !isDuplicateErr(err) || !isUniqueEmailErr(err) {
+				t.Errorf("duplicate insert: %v, want a unique email violation", err)
+			}
+			if isForeignKeyErr(err) {
+				t.Errorf("duplicate insert reported as a foreign key violation: %v", err)
+			}
+		})
+
+		t.Run("placeholders", func(t *testing.T) {
+			createTestContact(t, h, "Katherine", "katherine@example.com")
+			var n int
+			err := db.QueryRowContext(context.Background(), `
+SELECT COUNT(*) FROM contacts
+WHERE email = ? AND first_name <> '?' AND last_name <> 'a?b' AND id > ?`, "katherine@example.com", 0).Scan(&n)
+			if err != nil || n != 1 {
+				t.Errorf("count = %d, %v; want 1", n, err)
+			}
+		})
+	})
+}
+
+func TestRebind(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{`SELECT 1`, `SELECT 1`},
+		{`SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = $1 AND b = $2`},
+		{`SELECT '?' FROM t WHERE a = ?`, `SELECT '?' FROM t WHERE a = $1`},
+		{`SELECT "a?" FROM t WHERE a IN (?, ?, ?)`, `SELECT "a?" FROM t WHERE a IN ($1, $2, $3)`},
+		{`SELECT 'it''s ?' FROM t WHERE a = ?`, `SELECT 'it''s ?' FROM t WHERE a = $1`},
+	} {
+		if got := rebind(tt.in); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,1046 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: contacts.proto
+
+package contactspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListContactsRequest_SortField int32
+
+const (
+	ListContactsRequest_ID         ListContactsRequest_SortField = 0
+	ListContactsRequest_FIRST_NAME ListContactsRequest_SortField = 1
+	ListContactsRequest_LAST_NAME  ListContactsRequest_SortField = 2
+	ListContactsRequest_EMAIL      ListContactsRequest_SortField = 3
+	ListContactsRequest_COMPANY    ListContactsRequest_SortField = 4
+	ListContactsRequest_CREATED_AT ListContactsRequest_SortField = 5
+	ListContactsRequest_UPDATED_AT ListContactsRequest_SortField = 6
+)
+
+// Enum value maps for ListContactsRequest_SortField.
+var (
+	ListContactsRequest_SortField_name = map[int32]string{
+		0: "ID",
+		1: "FIRST_NAME",
+		2: "LAST_NAME",
+		3: "EMAIL",
+		4: "COMPANY",
+		5: "CREATED_AT",
+		6: "UPDATED_AT",
+	}
+	ListContactsRequest_SortField_value = map[string]int32{
+		"ID":         0,
+		"FIRST_NAME": 1,
+		"LAST_NAME":  2,
+		"EMAIL":      3,
+		"COMPANY":    4,
+		"CREATED_AT": 5,
+		"UPDATED_AT": 6,
+	}
+)
+
+func (x ListContactsRequest_SortField) Enum() *ListContactsRequest_SortField {
+	p := new(ListContactsRequest_SortField)
+	*p = x
+	return p
+}
+
+func (x ListContactsRequest_SortField) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ListContactsRequest_SortField) Descriptor() protoreflect.EnumDescriptor {
+	return file_contacts_proto_enumTypes[0].Descriptor()
+}
+
+func (ListContactsRequest_SortField) Type() protoreflect.EnumType {
+	return &file_contacts_proto_enumTypes[0]
+}
+
+func (x ListContactsRequest_SortField) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ListContactsRequest_SortField.Descriptor instead.
+func (ListContactsRequest_SortField) EnumDescriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type Contact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	FirstName     string                 `protobuf:"bytes,2,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName      string                 `protobuf:"bytes,3,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Company       *string                `protobuf:"bytes,4,opt,name=company,proto3,oneof" json:"company,omitempty"`
+	Email         string                 `protobuf:"bytes,5,opt,name=email,proto3" json:"email,omitempty"`
+	Phone         *string                `protobuf:"bytes,6,opt,name=phone,proto3,oneof" json:"phone,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	CustomFields  *structpb.Struct       `protobuf:"bytes,9,opt,name=custom_fields,json=customFields,proto3" json:"custom_fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Contact) Reset() {
+	*x = Contact{}
+	mi := &file_contacts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Contact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Contact) ProtoMessage() {}
+
+func (x *Contact) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Contact.ProtoReflect.Descriptor instead.
+func (*Contact) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Contact) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Contact) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *Contact) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+func (x *Contact) GetCompany() string {
+	if x != nil && x.Company != nil {
+		return *x.Company
+	}
+	return ""
+}
+
+func (x *Contact) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Contact) GetPhone() string {
+	if x != nil && x.Phone != nil {
+		return *x.Phone
+	}
+	return ""
+}
+
+func (x *Contact) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Contact) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Contact) GetCustomFields() *structpb.Struct {
+	if x != nil {
+		return x.CustomFields
+	}
+	return nil
+}
+
+type ContactInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FirstName     string                 `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName      string                 `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Company       *string                `protobuf:"bytes,3,opt,name=company,proto3,oneof" json:"company,omitempty"`
+	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Phone         *string                `protobuf:"bytes,5,opt,name=phone,proto3,oneof" json:"phone,omitempty"`
+	CustomFields  *structpb.Struct       `protobuf:"bytes,6,opt,name=custom_fields,json=customFields,proto3" json:"custom_fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactInput) Reset() {
+	*x = ContactInput{}
+	mi := &file_contacts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactInput) ProtoMessage() {}
+
+func (x *ContactInput) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactInput.ProtoReflect.Descriptor instead.
+func (*ContactInput) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ContactInput) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *ContactInput) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+func (x *ContactInput) GetCompany() string {
+	if x != nil && x.Company != nil {
+		return *x.Company
+	}
+	return ""
+}
+
+func (x *ContactInput) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *ContactInput) GetPhone() string {
+	if x != nil && x.Phone != nil {
+		return *x.Phone
+	}
+	return ""
+}
+
+func (x *ContactInput) GetCustomFields() *structpb.Struct {
+	if x != nil {
+		return x.CustomFields
+	}
+	return nil
+}
+
+type ContactPatch struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	FirstName *string                `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3,oneof" json:"first_name,omitempty"`
+	LastName  *string                `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3,oneof" json:"last_name,omitempty"`
+	Company   *string                `protobuf:"bytes,3,opt,name=company,proto3,oneof" json:"company,omitempty"`
+	Email     *string                `protobuf:"bytes,4,opt,name=email,proto3,oneof" json:"email,omitempty"`
+	Phone     *string                `protobuf:"bytes,5,opt,name=phone,proto3,oneof" json:"phone,omitempty"`
+	// Merged into the stored values; a null value clears that field.
+	CustomFields  *structpb.Struct `protobuf:"bytes,6,opt,name=custom_fields,json=customFields,proto3" json:"custom_fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactPatch) Reset() {
+	*x = ContactPatch{}
+	mi := &file_contacts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactPatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactPatch) ProtoMessage() {}
+
+func (x *ContactPatch) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactPatch.ProtoReflect.Descriptor instead.
+func (*ContactPatch) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ContactPatch) GetFirstName() string {
+	if x != nil && x.FirstName != nil {
+		return *x.FirstName
+	}
+	return ""
+}
+
+func (x *ContactPatch) GetLastName() string {
+	if x != nil && x.LastName != nil {
+		return *x.LastName
+	}
+	return ""
+}
+
+func (x *ContactPatch) GetCompany() string {
+	if x != nil && x.Company != nil {
+		return *x.Company
+	}
+	return ""
+}
+
+func (x *ContactPatch) GetEmail() string {
+	if x != nil && x.Email != nil {
+		return *x.Email
+	}
+	return ""
+}
+
+func (x *ContactPatch) GetPhone() string {
+	if x != nil && x.Phone != nil {
+		return *x.Phone
+	}
+	return ""
+}
+
+func (x *ContactPatch) GetCustomFields() *structpb.Struct {
+	if x != nil {
+		return x.CustomFields
+	}
+	return nil
+}
+
+type GetContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetContactRequest) Reset() {
+	*x = GetContactRequest{}
+	mi := &file_contacts_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetContactRequest) ProtoMessage() {}
+
+func (x *GetContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetContactRequest.ProtoReflect.Descriptor instead.
+func (*GetContactRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetContactRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListContactsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Substring match on first name, last name or email.
+	Search  string  `protobuf:"bytes,1,opt,name=search,proto3" json:"search,omitempty"`
+	Company *string `protobuf:"bytes,2,opt,name=company,proto3,oneof" json:"company,omitempty"`
+	Email   *string `protobuf:"bytes,3,opt,name=email,proto3,oneof" json:"email,omitempty"`
+	// Exact match on custom field values, like cf.<name>= on GET /contacts.
+	CustomFields map[string]string             `protobuf:"bytes,4,rep,name=custom_fields,json=customFields,proto3" json:"custom_fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Sort         ListContactsRequest_SortField `protobuf:"varint,5,opt,name=sort,proto3,enum=contacts.v1.ListContactsRequest_SortField" json:"sort,omitempty"`
+	Descending   bool                          `protobuf:"varint,6,opt,name=descending,proto3" json:"descending,omitempty"`
+	// Stop after this many contacts; 0 streams all of them.
+	Limit         int32 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListContactsRequest) Reset() {
+	*x = ListContactsRequest{}
+	mi := &file_contacts_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListContactsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListContactsRequest) ProtoMessage() {}
+
+func (x *ListContactsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListContactsRequest.ProtoReflect.Descriptor instead.
+func (*ListContactsRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListContactsRequest) GetSearch() string {
+	if x != nil {
+		return x.Search
+	}
+	return ""
+}
+
+func (x *ListContactsRequest) GetCompany() string {
+	if x != nil && x.Company != nil {
+		return *x.Company
+	}
+	return ""
+}
+
+func (x *ListContactsRequest) GetEmail() string {
+	if x != nil && x.Email != nil {
+		return *x.Email
+	}
+	return ""
+}
+
+func (x *ListContactsRequest) GetCustomFields() map[string]string {
+	if x != nil {
+		return x.CustomFields
+	}
+	return nil
+}
+
+func (x *ListContactsRequest) GetSort() ListContactsRequest_SortField {
+	if x != nil {
+		return x.Sort
+	}
+	return ListContactsRequest_ID
+}
+
+func (x *ListContactsRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+func (x *ListContactsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type CreateContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Contact       *ContactInput          `protobuf:"bytes,1,opt,name=contact,proto3" json:"contact,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateContactRequest) Reset() {
+	*x = CreateContactRequest{}
+	mi := &file_contacts_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateContactRequest) ProtoMessage() {}
+
+func (x *CreateContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateContactRequest.ProtoReflect.Descriptor instead.
+func (*CreateContactRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateContactRequest) GetContact() *ContactInput {
+	if x != nil {
+		return x.Contact
+	}
+	return nil
+}
+
+type UpdateContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Contact       *ContactInput          `protobuf:"bytes,2,opt,name=contact,proto3" json:"contact,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateContactRequest) Reset() {
+	*x = UpdateContactRequest{}
+	mi := &file_contacts_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateContactRequest) ProtoMessage() {}
+
+func (x *UpdateContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateContactRequest.ProtoReflect.Descriptor instead.
+func (*UpdateContactRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdateContactRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateContactRequest) GetContact() *ContactInput {
+	if x != nil {
+		return x.Contact
+	}
+	return nil
+}
+
+type PatchContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Patch         *ContactPatch          `protobuf:"bytes,2,opt,name=patch,proto3" json:"patch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PatchContactRequest) Reset() {
+	*x = PatchContactRequest{}
+	mi := &file_contacts_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchContactRequest) ProtoMessage() {}
+
+func (x *PatchContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchContactRequest.ProtoReflect.Descriptor instead.
+func (*PatchContactRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PatchContactRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *PatchContactRequest) GetPatch() *ContactPatch {
+	if x != nil {
+		return x.Patch
+	}
+	return nil
+}
+
+type DeleteContactRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteContactRequest) Reset() {
+	*x = DeleteContactRequest{}
+	mi := &file_contacts_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteContactRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteContactRequest) ProtoMessage() {}
+
+func (x *DeleteContactRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteContactRequest.ProtoReflect.Descriptor instead.
+func (*DeleteContactRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteContactRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// contact.created, contact.updated or contact.deleted; empty means all.
+	Types     []string `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`
+	ContactId int64    `protobuf:"varint,2,opt,name=contact_id,json=contactId,proto3" json:"contact_id,omitempty"`
+	// Resume after this event sequence number, like Last-Event-ID.
+	AfterSeq      int64 `protobuf:"varint,3,opt,name=after_seq,json=afterSeq,proto3" json:"after_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_contacts_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetContactId() int64 {
+	if x != nil {
+		return x.ContactId
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetAfterSeq() int64 {
+	if x != nil {
+		return x.AfterSeq
+	}
+	return 0
+}
+
+type ContactEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Seq   int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Id    string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// contact.created, contact.updated, contact.deleted, or "reset" when events
+	// after after_seq are no longer buffered and the client should re-list.
+	Type       string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	ContactId  int64                  `protobuf:"varint,4,opt,name=contact_id,json=contactId,proto3" json:"contact_id,omitempty"`
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	// The contact after the change; for deletions, its last state.
+	Contact       *Contact `protobuf:"bytes,6,opt,name=contact,proto3" json:"contact,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContactEvent) Reset() {
+	*x = ContactEvent{}
+	mi := &file_contacts_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContactEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactEvent) ProtoMessage() {}
+
+func (x *ContactEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_contacts_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactEvent.ProtoReflect.Descriptor instead.
+func (*ContactEvent) Descriptor() ([]byte, []int) {
+	return file_contacts_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ContactEvent) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ContactEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ContactEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ContactEvent) GetContactId() int64 {
+	if x != nil {
+		return x.ContactId
+	}
+	return 0
+}
+
+func (x *ContactEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *ContactEvent) GetContact() *Contact {
+	if x != nil {
+		return x.Contact
+	}
+	return nil
+}
+
+var File_contacts_proto protoreflect.FileDescriptor
+
+const file_contacts_proto_rawDesc = "" +
+	"\n" +
+	"\x0econtacts.proto\x12\vcontacts.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xef\x02\n" +
+	"\aContact\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"first_name\x18\x02 \x01(\tR\tfirstName\x12\x1b\n" +
+	"\tlast_name\x18\x03 \x01(\tR\blastName\x12\x1d\n" +
+	"\acompany\x18\x04 \x01(\tH\x00R\acompany\x88\x01\x01\x12\x14\n" +
+	"\x05email\x18\x05 \x01(\tR\x05email\x12\x19\n" +
+	"\x05phone\x18\x06 \x01(\tH\x01R\x05phone\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12<\n" +
+	"\rcustom_fields\x18\t \x01(\v2\x17.google.protobuf.StructR\fcustomFieldsB\n" +
+	"\n" +
+	"\b_companyB\b\n" +
+	"\x06_phone\"\xee\x01\n" +
+	"\fContactInput\x12\x1d\n" +
+	"\n" +
+	"first_name\x18\x01 \x01(\tR\tfirstName\x12\x1b\n" +
+	"\tlast_name\x18\x02 \x01(\tR\blastName\x12\x1d\n" +
+	"\acompany\x18\x03 \x01(\tH\x00R\acompany\x88\x01\x01\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\x19\n" +
+	"\x05phone\x18\x05 \x01(\tH\x01R\x05phone\x88\x01\x01\x12<\n" +
+	"\rcustom_fields\x18\x06 \x01(\v2\x17.google.protobuf.StructR\fcustomFieldsB\n" +
+	"\n" +
+	"\b_companyB\b\n" +
+	"\x06_phone\"\xa4\x02\n" +
+	"\fContactPatch\x12\"\n" +
+	"\n" +
+	"first_name\x18\x01 \x01(\tH\x00R\tfirstName\x88\x01\x01\x12 \n" +
+	"\tlast_name\x18\x02 \x01(\tH\x01R\blastName\x88\x01\x01\x12\x1d\n" +
+	"\acompany\x18\x03 \x01(\tH\x02R\acompany\x88\x01\x01\x12\x19\n" +
+	"\x05email\x18\x04 \x01(\tH\x03R\x05email\x88\x01\x01\x12\x19\n" +
+	"\x05phone\x18\x05 \x01(\tH\x04R\x05phone\x88\x01\x01\x12<\n" +
+	"\rcustom_fields\x18\x06 \x01(\v2\x17.google.protobuf.StructR\fcustomFieldsB\r\n" +
+	"\v_first_nameB\f\n" +
+	"\n" +
+	"_last_nameB\n" +
+	"\n" +
+	"\b_companyB\b\n" +
+	"\x06_emailB\b\n" +
+	"\x06_phone\"#\n" +
+	"\x11GetContactRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xf9\x03\n" +
+	"\x13ListContactsRequest\x12\x16\n" +
+	"\x06search\x18\x01 \x01(\tR\x06search\x12\x1d\n" +
+	"\acompany\x18\x02 \x01(\tH\x00R\acompany\x88\x01\x01\x12\x19\n" +
+	"\x05email\x18\x03 \x01(\tH\x01R\x05email\x88\x01\x01\x12W\n" +
+	"\rcustom_fields\x18\x04 \x03(\v22.contacts.v1.ListContactsRequest.CustomFieldsEntryR\fcustomFields\x12>\n" +
+	"\x04sort\x18\x05 \x01(\x0e2*.contacts.v1.ListContactsRequest.SortFieldR\x04sort\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x06 \x01(\bR\n" +
+	"descending\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\x1a?\n" +
+	"\x11CustomFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"j\n" +
+	"\tSortField\x12\x06\n" +
+	"\x02ID\x10\x00\x12\x0e\n" +
+	"\n" +
+	"FIRST_NAME\x10\x01\x12\r\n" +
+	"\tLAST_NAME\x10\x02\x12\t\n" +
+	"\x05EMAIL\x10\x03\x12\v\n" +
+	"\aCOMPANY\x10\x04\x12\x0e\n" +
+	"\n" +
+	"CREATED_AT\x10\x05\x12\x0e\n" +
+	"\n" +
+	"UPDATED_AT\x10\x06B\n" +
+	"\n" +
+	"\b_companyB\b\n" +
+	"\x06_email\"K\n" +
+	"\x14CreateContactRequest\x123\n" +
+	"\acontact\x18\x01 \x01(\v2\x19.contacts.v1.ContactInputR\acontact\"[\n" +
+	"\x14UpdateContactRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x123\n" +
+	"\acontact\x18\x02 \x01(\v2\x19.contacts.v1.ContactInputR\acontact\"V\n" +
+	"\x13PatchContactRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12/\n" +
+	"\x05patch\x18\x02 \x01(\v2\x19.contacts.v1.ContactPatchR\x05patch\"&\n" +
+	"\x14DeleteContactRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"`\n" +
+	"\fWatchRequest\x12\x14\n" +
+	"\x05types\x18\x01 \x03(\tR\x05types\x12\x1d\n" +
+	"\n" +
+	"contact_id\x18\x02 \x01(\x03R\tcontactId\x12\x1b\n" +
+	"\tafter_seq\x18\x03 \x01(\x03R\bafterSeq\"\xd0\x01\n" +
+	"\fContactEvent\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1d\n" +
+	"\n" +
+	"contact_id\x18\x04 \x01(\x03R\tcontactId\x12;\n" +
+	"\voccurred_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12.\n" +
+	"\acontact\x18\x06 \x01(\v2\x14.contacts.v1.ContactR\acontact2\x85\x04\n" +
+	"\x0eContactService\x12B\n" +
+	"\n" +
+	"GetContact\x12\x1e.contacts.v1.GetContactRequest\x1a\x14.contacts.v1.Contact\x12H\n" +
+	"\fListContacts\x12 .contacts.v1.ListContactsRequest\x1a\x14.contacts.v1.Contact0\x01\x12H\n" +
+	"\rCreateContact\x12!.contacts.v1.CreateContactRequest\x1a\x14.contacts.v1.Contact\x12H\n" +
+	"\rUpdateContact\x12!.contacts.v1.UpdateContactRequest\x1a\x14.contacts.v1.Contact\x12F\n" +
+	"\fPatchContact\x12 .contacts.v1.PatchContactRequest\x1a\x14.contacts.v1.Contact\x12H\n" +
+	"\rDeleteContact\x12!.contacts.v1.DeleteContactRequest\x1a\x14.contacts.v1.Contact\x12?\n" +
+	"\x05Watch\x12\x19.contacts.v1.WatchRequest\x1a\x19.contacts.v1.ContactEvent0\x01B\x16Z\x14my-go-api/contactspbb\x06proto3"
+
+var (
+	file_contacts_proto_rawDescOnce sync.Once
+	file_contacts_proto_rawDescData []byte
+)
+
+func file_contacts_proto_rawDescGZIP() []byte {
+	file_contacts_proto_rawDescOnce.Do(func() {
+		file_contacts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_contacts_proto_rawDesc), len(file_contacts_proto_rawDesc)))
+	})
+	return file_contacts_proto_rawDescData
+}
+
+var file_contacts_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_contacts_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_contacts_proto_goTypes = []any{
+	(ListContactsRequest_SortField)(0), // 0: contacts.v1.ListContactsRequest.SortField
+	(*Contact)(nil),                    // 1: contacts.v1.Contact
+	(*ContactInput)(nil),               // 2: contacts.v1.ContactInput
+	(*ContactPatch)(nil),               // 3: contacts.v1.ContactPatch
+	(*GetContactRequest)(nil),          // 4: contacts.v1.GetContactRequest
+	(*ListContactsRequest)(nil),        // 5: contacts.v1.ListContactsRequest
+	(*CreateContactRequest)(nil),       // 6: contacts.v1.CreateContactRequest
+	(*UpdateContactRequest)(nil),       // 7: contacts.v1.UpdateContactRequest
+	(*PatchContactRequest)(nil),        // 8: contacts.v1.PatchContactRequest
+	(*DeleteContactRequest)(nil),       // 9: contacts.v1.DeleteContactRequest
+	(*WatchRequest)(nil),               // 10: contacts.v1.WatchRequest
+	(*ContactEvent)(nil),               // 11: contacts.v1.ContactEvent
+	nil,                                // 12: contacts.v1.ListContactsRequest.CustomFieldsEntry
+	(*timestamppb.Timestamp)(nil),      // 13: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),            // 14: google.protobuf.Struct
+}
+var file_contacts_proto_depIdxs = []int32{
+	13, // 0: contacts.v1.Contact.created_at:type_name -> google.protobuf.Timestamp
+	13, // 1: contacts.v1.Contact.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 2: contacts.v1.Contact.custom_fields:type_name -> google.protobuf.Struct
+	14, // 3: contacts.v1.ContactInput.custom_fields:type_name -> google.protobuf.Struct
+	14, // 4: contacts.v1.ContactPatch.custom_fields:type_name -> google.protobuf.Struct
+	12, // 5: contacts.v1.ListContactsRequest.custom_fields:type_name -> contacts.v1.ListContactsRequest.CustomFieldsEntry
+	0,  // 6: contacts.v1.ListContactsRequest.sort:type_name -> contacts.v1.ListContactsRequest.SortField
+	2,  // 7: contacts.v1.CreateContactRequest.contact:type_name -> contacts.v1.ContactInput
+	2,  // 8: contacts.v1.UpdateContactRequest.contact:type_name -> contacts.v1.ContactInput
+	3,  // 9: contacts.v1.PatchContactRequest.patch:type_name -> contacts.v1.ContactPatch
+	13, // 10: contacts.v1.ContactEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	1,  // 11: contacts.v1.ContactEvent.contact:type_name -> contacts.v1.Contact
+	4,  // 12: contacts.v1.ContactService.GetContact:input_type -> contacts.v1.GetContactRequest
+	5,  // 13: contacts.v1.ContactService.ListContacts:input_type -> contacts.v1.ListContactsRequest
+	6,  // 14: contacts.v1.ContactService.CreateContact:input_type -> contacts.v1.CreateContactRequest
+	7,  // 15: contacts.v1.ContactService.UpdateContact:input_type -> contacts.v1.UpdateContactRequest
+	8,  // 16: contacts.v1.ContactService.PatchContact:input_type -> contacts.v1.PatchContactRequest
+	9,  // 17: contacts.v1.ContactService.DeleteContact:input_type -> contacts.v1.DeleteContactRequest
+	10, // 18: contacts.v1.ContactService.Watch:input_type -> contacts.v1.WatchRequest
+	1,  // 19: contacts.v1.ContactService.GetContact:output_type -> contacts.v1.Contact
+	1,  // 20: contacts.v1.ContactService.ListContacts:output_type -> contacts.v1.Contact
+	1,  // 21: contacts.v1.ContactService.CreateContact:output_type -> contacts.v1.Contact
+	1,  // 22: contacts.v1.ContactService.UpdateContact:output_type -> contacts.v1.Contact
+	1,  // 23: contacts.v1.ContactService.PatchContact:output_type -> contacts.v1.Contact
+	1,  // 24: contacts.v1.ContactService.DeleteContact:output_type -> contacts.v1.Contact
+	11, // 25: contacts.v1.ContactService.Watch:output_type -> contacts.v1.ContactEvent
+	19, // [19:26] is the sub-list for method output_type
+	12, // [12:19] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_contacts_proto_init() }
+func file_contacts_proto_init() {
+	if File_contacts_proto != nil {
+		return
+	}
+	file_contacts_proto_msgTypes[0].OneofWrappers = []any{}
+	file_contacts_proto_msgTypes[1].OneofWrappers = []any{}
+	file_contacts_proto_msgTypes[2].OneofWrappers = []any{}
+	file_contacts_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_contacts_proto_rawDesc), len(file_contacts_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_contacts_proto_goTypes,
+		DependencyIndexes: file_contacts_proto_depIdxs,
+		EnumInfos:         file_contacts_proto_enumTypes,
+		MessageInfos:      file_contacts_proto_msgTypes,
+	}.Build()
+	File_contacts_proto = out.File
+	file_contacts_proto_goTypes = nil
+	file_contacts_proto_depIdxs = nil
+}
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Custom field types supported on contacts.
+const (
+	fieldString = "string"
+	fieldNumber = "number"
+	fieldDate   = "date"
+	fieldEnum   = "enum"
+	fieldURL    = "url"
+)
+
+const dateLayout = "2006-01-02"
+
+// customFieldFilterPrefix marks list/export query params that filter on a
+// custom field value, e.g. ?cf.accountManager=Grace
+const customFieldFilterPrefix = "cf."
+
+var fieldNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+// CustomField is an admin-defined, typed attribute that can be set on any contact.
+type CustomField struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Label     string     `json:"label"`
+	Type      string     `json:"type"`
+	Required  bool       `json:"required"`
+	Rules     FieldRules `json:"rules"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// FieldRules holds the optional validation rules for a custom field.
+// Which rules apply depends on the field type.
+type FieldRules struct {
+	Options   []string `json:"options,omitempty"`   // enum
+	Pattern   string   `json:"pattern,omitempty"`   // string
+	MinLength *int     `json:"minLength,omitempty"` // string
+	MaxLength *int     `json:"maxLength,omitempty"` // string
+	Min       *float64 `json:"min,omitempty"`       // number
+	Max       *float64 `json:"max,omitempty"`       // number
+}
+
+type CustomFieldInput struct {
+	Name     string     `json:"name"`
+	Label    string     `json:"label"`
+	Type     string     `json:"type"`
+	Required bool       `json:"required"`
+	Rules    FieldRules `json:"rules"`
+}
+
+const customFieldsDDL = `
+CREATE TABLE IF NOT EXISTS custom_fields (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name       VARCHAR(64)  NOT NULL UNIQUE,
+  label      VARCHAR(255) NOT NULL,
+  type       VARCHAR(16)  NOT NULL,
+  required   BOOLEAN      NOT NULL DEFAULT FALSE,
+  rules      TEXT         NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// maxFieldValueLength is the size of contact_field_values.value; it caps
+// every value whatever the field's own rules allow.
+const maxFieldValueLength = 2048
+
+const contactFieldValuesDDL = `
+CREATE TABLE IF NOT EXISTS contact_field_values (
+  contact_id BIGINT NOT NULL,
+  field_id   BIGINT NOT NULL,
+  value      VARCHAR(2048) NOT NULL,
+  PRIMARY KEY (contact_id, field_id),
+  INDEX idx_field_value (field_id, value(191)),
+  FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE CASCADE,
+  FOREIGN KEY (field_id) REFERENCES custom_fields(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+func listCustomFields(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	defs, err := loadCustomFields(ctx, db)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if defs == nil {
+		defs = []CustomField{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": defs})
+}
+
+func getCustomField(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	defs, err := loadCustomFields(ctx, db)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	f, ok := fieldsByName(defs)[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("custom field %q not found", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+func createCustomField(w http.ResponseWriter, r *http.Request) {
+	var in CustomFieldInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateFieldInput(&in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	rules, err := json.Marshal(in.Rules)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	id, err := insertID(ctx, db, `
+INSERT INTO custom_fields (name, label, type, required, rules, created_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		in.Name, in.Label, in.Type, in.Required, string(rules), now)
+	if err != nil {
+		if isDuplicateErr(err) {
+			writeError(w, http.StatusConflict, fmt.Errorf("custom field %q already exists", in.Name))
+			return
+		}
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, CustomField{
+		ID:        id,
+		Name:      in.Name,
+		Label:     in.Label,
+		Type:      in.Type,
+		Required:  in.Required,
+		Rules:     in.Rules,
+		CreatedAt: now,
+	})
+}
+
+// deleteCustomField removes a field definition; stored values are dropped by the FK cascade.
+func deleteCustomField(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `DELETE FROM custom_fields WHERE name = ?`, name)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("custom field %q not found", name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// exportBatch is how many contacts exportContactsCSV reads per query.
+const exportBatch = 500
+
+// exportContactsCSV streams every contact matching the list filters as CSV,
+// with one column per custom field after the built-in columns. Under v2 the
+// email and phone columns are "emails" and "phones", each a ";"-separated
+// list with the primary entry first. Contacts are read in batches of
+// exportBatch by id, each query with its own deadline, and written out as
+// each batch arrives.
+func exportContactsCSV(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := dbCtx(r.Context())
+	defs, err := loadCustomFields(ctx, db)
+	cancel()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	where, args, err := customFieldFilters(defs, r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if where == "" {
+		where = "\nWHERE id > ?"
+	} else {
+		where += "\n  AND id > ?"
+	}
+	query := `
+SELECT ` + contactColumns + `
+FROM contacts` + where + `
+ORDER BY id
+LIMIT ?`
+	batch := func(after int64) ([]Contact, error) {
+		ctx, cancel := dbCtx(r.Context())
+		defer cancel()
+		rows, err := stmts.query(ctx, db, query, append(slices.Clip(args), after, exportBatch)...)
+		if err != nil {
+			return nil, err
+		}
+		items, err := scanContacts(rows)
+		if err != nil {
+			return nil, err
+		}
+		return items, attachCustomValues(ctx, db, defs, items)
+	}
+
+	items, err := batch(0)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	// The export outlives the server's WriteTimeout on a large table.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="contacts.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	v2 := apiVersionOf(r) == "v2"
+	header := []string{"id", "firstName", "lastName", "company", "email", "phone", "createdAt", "updatedAt"}
+	if v2 {
+		header[4], header[5] = "emails", "phones"
+	}
+	for _, f := range defs {
+		header = append(header, f.Name)
+	}
+	_ = cw.Write(header)
+	for len(items) > 0 {
+		for _, c := range items {
+			email, phone := c.Email, deref(c.Phone)
+			if v2 {
+				email, phone = csvContactLists(contactV2(c))
+			}
+			rec := []string{
+				strconv.FormatInt(c.ID, 10),
+				c.FirstName,
+				c.LastName,
+				deref(c.Company),
+				email,
+				phone,
+				c.CreatedAt.UTC().Format(time.RFC3339),
+				c.UpdatedAt.UTC().Format(time.RFC3339),
+			}
+			for _, f := range defs {
+				v, ok := c.CustomFields[f.Name]
+				if !ok {
+					rec = append(rec, "")
+					continue
+				}
+				rec = append(rec, formatFieldValue(v))
+			}
+			for i := range rec {
+				rec[i] = csvSafe(rec[i])
+			}
+			_ = cw.Write(rec)
+		}
+		cw.Flush()
+		if cw.Error() != nil || rc.Flush() != nil || len(items) < exportBatch {
+			return
+		}
+		if items, err = batch(items[len(items)-1].ID); err != nil {
+			// Too late for an error status; a short file is all the client sees.
+			slog.ErrorContext(r.Context(), "export contacts", "err", err)
+			return
+		}
+	}
+}
+
+// csvSafe keeps a spreadsheet from reading a cell as a formula, by quoting
+// it with a leading ' as the spreadsheets themselves do.
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Storage
+
+// loadCustomFields reads the field definitions through q. Inside a
+// transaction pass the *sql.Tx: asking db instead waits for a second
+// connection while the transaction holds one.
+func loadCustomFields(ctx context.Context, q queryer) ([]CustomField, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := stmts.query(ctx, q, sqlCustomFields)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []CustomField
+	for rows.Next() {
+		var f CustomField
+		var rules string
+		if err := rows.Scan(&f.ID, &f.Name, &f.Label, &f.Type, &f.Required, &rules, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(rules), &f.Rules); err != nil {
+			return nil, fmt.Errorf("custom field %q: decode rules: %w", f.Name, err)
+		}
+		defs = append(defs, f)
+	}
+	return defs, rows.Err()
+}
+
+func fieldsByName(defs []CustomField) map[string]CustomField {
+	m := make(map[string]CustomField, len(defs))
+	for _, f := range defs {
+		m[f.Name] = f
+	}
+	return m
+}
+
+// saveCustomValues writes canonical values for a contact inside tx. A nil value
+// deletes the stored value. When replace is true, every existing value not
+// present in vals is removed first (PUT semantics).
+func saveCustomValues(ctx context.Context, tx *sql.Tx, contactID int64, defs []CustomField, vals map[string]*string, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM contact_field_values WHERE contact_id = ?`, contactID); err != nil {
+			return err
+		}
+	}
+	byName := fieldsByName(defs)
+	for name, v := range vals {
+		f := byName[name]
+		if v == nil {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM contact_field_values WHERE contact_id = ? AND field_id = ?`, contactID, f.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, dialect.upsert(`
+INSERT INTO contact_field_values (contact_id, field_id, value)
+VALUES (?, ?, ?)`, []string{"contact_id", "field_id"}, "value"), contactID, f.ID, *v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachCustomValues loads the stored custom field values for items in one query.
+func attachCustomValues(ctx context.Context, q queryer, defs []CustomField, items []Contact) error {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	if len(items) == 0 || len(defs) == 0 {
+		return nil
+	}
+	byID := make(map[int64]CustomField, len(defs))
+	for _, f := range defs {
+		byID[f.ID] = f
+	}
+	index := make(map[int64]int, len(items))
+	ids := make([]int64, len(items))
+	for i, c := range items {
+		index[c.ID] = i
+		ids[i] = c.ID
+	}
+
+	in, args := inClause(ids)
+	rows, err := stmts.query(ctx, q, `
+SELECT contact_id, field_id, value
+FROM contact_field_values
+WHERE contact_id IN (`+in+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contactID, fieldID int64
+		var value string
+		if err := rows.Scan(&contactID, &fieldID, &value); err != nil {
+			return err
+		}
+		f, ok := byID[fieldID]
+		if !ok {
+			continue
+		}
+		c := &items[index[contactID]]
+		if c.CustomFields == nil {
+			c.CustomFields = make(map[string]any)
+		}
+		c.CustomFields[f.Name] = f.decode(value)
+	}
+	return rows.Err()
+}
+
+// customFieldFilters turns cf.<name>=<value> query params into a WHERE clause
+// on contacts. Values are canonicalized the same way they are stored.
+func customFieldFilters(defs []CustomField, q url.Values) (string, []any, error) {
+	byName := fieldsByName(defs)
+	var conds []string
+	var args []any
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, customFieldFilterPrefix)
+		if !ok {
+			continue
+		}
+		f, ok := byName[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown custom field %q", name)
+		}
+		for _, raw := range values {
+			v, err := f.canonicalizeQuery(raw)
+			if err != nil {
+				return "", nil, err
+			}
+			conds = append(conds, `EXISTS (
+  SELECT 1 FROM contact_field_values v
+  WHERE v.contact_id = contacts.id AND v.field_id = ? AND `+dialect.equalFold("v.value")+`)`)
+			args = append(args, f.ID, v)
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return "\nWHERE " + strings.Join(conds, "\n  AND "), args, nil
+}
+
+// Validation
+
+func validateFieldInput(in *CustomFieldInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if !fieldNameRegex.MatchString(in.Name) {
+		return fmt.Errorf("invalid name: must start with a letter and contain only letters, digits and underscores")
+	}
+	if strings.TrimSpace(in.Label) == "" {
+		in.Label = in.Name
+	}
+	switch in.Type {
+	case fieldString:
+		if in.Rules.Pattern != "" {
+			if _, err := regexp.Compile(in.Rules.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern: %v", err)
+			}
+		}
+		if in.Rules.MinLength != nil && in.Rules.MaxLength != nil && *in.Rules.MinLength > *in.Rules.MaxLength {
+			return fmt.Errorf("minLength must not exceed maxLength")
+		}
+	case fieldNumber:
+		if in.Rules.Min != nil && in.Rules.Max != nil && *in.Rules.Min > *in.Rules.Max {
+			return fmt.Errorf("min must not exceed max")
+		}
+	case fieldEnum:
+		if len(in.Rules.Options) == 0 {
+			return fmt.Errorf("enum fields require at least one option")
+		}
+	case fieldDate, fieldURL:
+	default:
+		return fmt.Errorf("invalid type %q: must be one of string, number, date, enum, url", in.Type)
+	}
+	return nil
+}
+
+// validateCustomValues checks raw JSON values against the field definitions and
+// returns their canonical stored form. A nil entry means "clear this value".
+// When requireAll is true (create and full update) every required field must be set.
+func validateCustomValues(defs []CustomField, in map[string]any, requireAll bool) (map[string]*string, error) {
+	byName := fieldsByName(defs)
+	out := make(map[string]*string, len(in))
+	for name, raw := range in {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field %q", name)
+		}
+		if raw == nil {
+			if f.Required {
+				return nil, fmt.Errorf("customFields.%s is required", name)
+			}
+			out[name] = nil
+			continue
+		}
+		v, err := f.canonicalize(raw)
+		if err != nil {
+			return nil, err
+		}
+		if utf8.RuneCountInString(v) > maxFieldValueLength {
+			return nil, fmt.Errorf("customFields.%s must be at most %d characters", name, maxFieldValueLength)
+		}
+		out[name] = &v
+	}
+	if requireAll {
+		for _, f := range defs {
+			if f.Required && out[f.Name] == nil {
+				return nil, fmt.Errorf("customFields.%s is required", f.Name)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f CustomField) canonicalize(raw any) (string, error) {
+	switch f.Type {
+	case fieldNumber:
+		n, ok := raw.(float64)
+		if !ok {
+			return "", fmt.Errorf("customFields.%s must be a number", f.Name)
+		}
+		if f.Rules.Min != nil && n < *f.Rules.Min {
+			return "", fmt.Errorf("customFields.%s must be >= %v", f.Name, *f.Rules.Min)
+		}
+		if f.Rules.Max != nil && n > *f.Rules.Max {
+			return "", fmt.Errorf("customFields.%s must be <= %v", f.Name, *f.Rules.Max)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	}
+
+	s, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("customFields.%s must be a string", f.Name)
+	}
+	switch f.Type {
+	case fieldString:
+		n := utf8.RuneCountInString(s)
+		if f.Rules.MinLength != nil && n < *f.Rules.MinLength {
+			return "", fmt.Errorf("customFields.%s must be at least %d characters", f.Name, *f.Rules.MinLength)
+		}
+		if f.Rules.MaxLength != nil && n > *f.Rules.MaxLength {
+			return "", fmt.Errorf("customFields.%s must be at most %d characters", f.Name, *f.Rules.MaxLength)
+		}
+		if f.Rules.Pattern != "" {
+			re, err := regexp.Compile(f.Rules.Pattern)
+			if err != nil {
+				return "", fmt.Errorf("customFields.%s: invalid pattern: %v", f.Name, err)
+			}
+			if !re.MatchString(s) {
+				return "", fmt.Errorf("customFields.%s does not match pattern %s", f.Name, f.Rules.Pattern)
+			}
+		}
+		return s, nil
+	case fieldDate:
+		d, err := time.Parse(dateLayout, strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf("customFields.%s must be a date (YYYY-MM-DD)", f.Name)
+		}
+		return d.Format(dateLayout), nil
+	case fieldEnum:
+		for _, opt := range f.Rules.Options {
+			if s == opt {
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("customFields.%s must be one of %s", f.Name, strings.Join(f.Rules.Options, ", "))
+	case fieldURL:
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("customFields.%s must be an http(s) URL", f.Name)
+		}
+		return u.String(), nil
+	}
+	return "", fmt.Errorf("customFields.%s has unsupported type %q", f.Name, f.Type)
+}
+
+// canonicalizeQuery is canonicalize for values that arrive as query strings.
+func (f CustomField) canonicalizeQuery(s string) (string, error) {
+	if f.Type == fieldNumber {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", fmt.Errorf("cf.%s must be a number", f.Name)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	}
+	return f.canonicalize(s)
+}
+
+// decode maps a stored value back to its JSON representation.
+func (f CustomField) decode(s string) any {
+	if f.Type == fieldNumber {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
+
+// decodeCustomValues converts validated values back to JSON form, dropping cleared ones.
+func decodeCustomValues(defs []CustomField, vals map[string]*string) map[string]any {
+	byName := fieldsByName(defs)
+	out := make(map[string]any, len(vals))
+	for name, v := range vals {
+		if v != nil {
+			out[name] = byName[name].decode(*v)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func formatFieldValue(v any) string {
+	if n, ok := v.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
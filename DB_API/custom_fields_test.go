@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSVSafe(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                    "",
+		"Ada":                 "Ada",
+		"=HYPERLINK(\"x\")":   "'=HYPERLINK(\"x\")",
+		"+1-555-0100":         "'+1-555-0100",
+		"-2":                  "'-2",
+		"@SUM(A1)":            "'@SUM(A1)",
+		"\t=1":                "'\t=1",
+		"ada+tag@example.com": "ada+tag@example.com",
+	} {
+		if got := csvSafe(in); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestExportContactsCSV exports more contacts than one batch holds.
+func TestExportContactsCSV(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		if status := do(t, h, http.MethodPost, "/custom-fields", map[string]any{"name": "tier", "type": "string"}, nil); status != http.StatusCreated {
+			t.Fatalf("create field: status %d", status)
+		}
+		ctx := context.Background()
+		n := exportBatch + 5
+		for i := range n {
+			in := ContactInput{FirstName: "Ada", LastName: fmt.Sprint(i), Email: fmt.Sprintf("ada%d@example.com", i)}
+			if i == 0 {
+				in.FirstName = "=cmd|' /C calc'!A0"
+			}
+			if i%2 == 0 {
+				in.CustomFields = map[string]any{"tier": "gold"}
+			}
+			if _, err := insertContact(ctx, in); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tt := range []struct {
+			query string
+			rows  int
+			email string
+		}{
+			{"/contacts/export.csv", n, "email"},
+			{"/contacts/export.csv?cf.tier=gold", (n + 1) / 2, "email"},
+			{"/v2/contacts/export.csv", n, "emails"},
+		} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: status %d", tt.query, w.Code)
+			}
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tt.rows+1 || records[0][len(records[0])-1] != "tier" {
+				t.Fatalf("%s: %d records, header %v; want %d rows", tt.query, len(records), records[0], tt.rows)
+			}
+			if records[0][4] != tt.email {
+				t.Errorf("%s: header %v, want column %q", tt.query, records[0], tt.email)
+			}
+			if first := records[1]; first[1] != "'=cmd|' /C calc'!A0" || first[4] != "ada0@example.com" || first[len(first)-1] != "gold" {
+				t.Errorf("%s: first row %v", tt.query, first)
+			}
+			seen := make(map[string]bool)
+			for _, rec := range records[1:] {
+				if seen[rec[0]] {
+					t.Fatalf("%s: contact %s twice", tt.query, rec[0])
+				}
+				seen[rec[0]] = true
+			}
+		}
+	})
+}
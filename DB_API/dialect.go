@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DB_API runs on MySQL (db.driver "mysql", the default) or PostgreSQL
+// ("postgres"). Queries are written for MySQL with ? placeholders, which the
+// PostgreSQL connection rewrites to $1, $2, ...; the few statements that
+// cannot be written portably ask the dialect for the SQL.
+type sqlDialect interface {
+	// migrations is the schema, in order.
+	migrations() []string
+	// migrate applies migrations to db.
+	migrate(ctx context.Context, db *sql.DB) error
+	// currentSchema is the SQL expression naming the schema the tables are in.
+	currentSchema() string
+	// like is a LIKE that ignores case, as MySQL's does under utf8mb4.
+	like() string
+	// equalFold is a condition comparing col to a placeholder, ignoring case.
+	equalFold(col string) string
+	// nullsFirst is appended to an ORDER BY term so that NULLs sort as on
+	// MySQL: first ascending, last descending.
+	nullsFirst(desc bool) string
+	// insertIgnore makes an INSERT skip rows that would duplicate a unique key.
+	insertIgnore(insert string) string
+	// upsert makes an INSERT update cols from the new row when it would
+	// duplicate the unique key on keyCols.
+	upsert(insert string, keyCols []string, cols ...string) string
+	// returningID rewrites an INSERT to return the new row's id, or returns
+	// false when the driver reports it through LastInsertId.
+	returningID(insert string) (string, bool)
+	// noteSearch is the condition matching a note against a full-text query,
+	// and its relevance; each takes the query as its one placeholder.
+	noteSearch() (match, score string)
+}
+
+// dialect is the SQL dialect of db, set at startup from db.driver.
+var dialect sqlDialect = mysqlDialect{}
+
+func dialectFor(driver string) sqlDialect {
+	if driver == "postgres" {
+		return postgresDialect{}
+	}
+	return mysqlDialect{}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) migrations() []string { return mysqlMigrations }
+
+// migrate runs each statement on its own: MySQL commits DDL as it goes, and
+// CREATE ... IF NOT EXISTS makes concurrent startups safe.
+func (mysqlDialect) migrate(ctx context.Context, db *sql.DB) error {
+	for _, ddl := range mysqlMigrations {
+		if _, err := db.ExecContext(ctx, ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mysqlDialect) currentSchema() string       { return "DATABASE()" }
+func (mysqlDialect) like() string                { return "LIKE" }
+func (mysqlDialect) equalFold(col string) string { return col + " = ?" }
+func (mysqlDialect) nullsFirst(bool) string      { return "" }
+
+func (mysqlDialect) insertIgnore(insert string) string {
+	return strings.Replace(insert, "INSERT INTO", "INSERT IGNORE INTO", 1)
+}
+
+func (mysqlDialect) upsert(insert string, _ []string, cols ...string) string {
+	set := make([]string, len(cols))
+	for i, c := range cols {
+		set[i] = fmt.Sprintf("%s = VALUES(%s)", c, c)
+	}
+	return insert + "\nON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (mysqlDialect) returningID(insert string) (string, bool) { return insert, false }
+
+func (mysqlDialect) noteSearch() (match, score string) {
+	const m = "MATCH(subject, body) AGAINST (? IN NATURAL LANGUAGE MODE)"
+	return m, m
+}
+
+// insertID runs an INSERT into a table keyed by an id column and returns
+// the new row's id.
+func insertID(ctx context.Context, q dbtx, insert string, args ...any) (int64, error) {
+	if query, ok := dialect.returningID(insert); ok {
+		var id int64
+		err := stmts.queryRow(ctx, q, query, args...).Scan(&id)
+		return id, err
+	}
+	res, err := stmts.exec(ctx, q, insert, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Error numbers and SQLSTATEs for the constraint violations callers report
+// as client errors.
+const (
+	mysqlDupEntry         = 1062
+	mysqlRowIsReferenced  = 1451
+	mysqlNoReferencedRow  = 1452
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// isDuplicateErr reports whether err is a unique key violation.
+func isDuplicateErr(err error) bool {
+	var me *mysql.MySQLError
+	var pe *pgconn.PgError
+	switch {
+	case errors.As(err, &me):
+		return me.Number == mysqlDupEntry
+	case errors.As(err, &pe):
+		return pe.Code == pgUniqueViolation
+	}
+	return false
+}
+
+// isUniqueEmailErr reports whether err is a violation of the unique key on
+// contacts.email. Callers pass errors from writes to contacts, where that is
+// the only unique key, so the error number or SQLSTATE decides; the key name
+// is only checked when the server gives one, which not every MySQL-compatible
+// server does.
+func isUniqueEmailErr(err error) bool {
+	if !isDuplicateErr(err) {
+		return false
+	}
+	var me *mysql.MySQLError
+	var pe *pgconn.PgError
+	key := ""
+	switch {
+	case errors.As(err, &me):
+		// Duplicate entry 'a@example.com' for key 'contacts.email'
+		if i := strings.LastIndex(me.Message, " for key "); i >= 0 {
+			key = me.Message[i:]
+		}
+	case errors.As(err, &pe):
+		key = pe.ConstraintName
+	}
+	return key == "" || strings.Contains(key, "email")
+}
+
+// isForeignKeyErr reports whether err is a foreign key violation: a
+// reference to a row that does not exist, or removing one still referenced.
+func isForeignKeyErr(err error) bool {
+	var me *mysql.MySQLError
+	var pe *pgconn.PgError
+	switch {
+	case errors.As(err, &me):
+		return me.Number == mysqlNoReferencedRow || me.Number == mysqlRowIsReferenced
+	case errors.As(err, &pe):
+		return pe.Code == pgForeignKeyViolation
+	}
+	return false
+}
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Pairs scoring at or above this are stored as duplicate candidates.
+const defaultDuplicateThreshold = 0.75
+
+// Weights for the individual similarity signals. When a signal is missing on
+// either side (e.g. no phone) its weight is dropped and the rest renormalized.
+const (
+	nameWeight  = 0.5
+	emailWeight = 0.3
+	phoneWeight = 0.2
+)
+
+const (
+	duplicateOpen      = "open"
+	duplicateDismissed = "dismissed"
+)
+
+// DuplicateCandidate is a pair of contacts that the scan job considers likely duplicates.
+// ContactA always has the lower id.
+type DuplicateCandidate struct {
+	ID         int64              `json:"id"`
+	ContactA   Contact            `json:"contactA"`
+	ContactB   Contact            `json:"contactB"`
+	Score      float64            `json:"score"`
+	Signals    map[string]float64 `json:"signals"`
+	Status     string             `json:"status"`
+	DetectedAt time.Time          `json:"detectedAt"`
+}
+
+// MergeInput selects, per field, whether the surviving (target) contact keeps
+// its own value or takes the source's. Fields not listed keep the target
+// value, falling back to the source when the target's is empty.
+type MergeInput struct {
+	SourceID     int64             `json:"sourceId"`
+	Fields       map[string]string `json:"fields"`
+	CustomFields map[string]string `json:"customFields"`
+}
+
+// ContactMerge is the audit record written for every merge.
+type ContactMerge struct {
+	ID       int64           `json:"id"`
+	TargetID int64           `json:"targetId"`
+	SourceID int64           `json:"sourceId"`
+	Choices  json.RawMessage `json:"choices"`
+	Source   json.RawMessage `json:"source"`
+	Result   json.RawMessage `json:"result"`
+	MergedAt time.Time       `json:"mergedAt"`
+}
+
+const (
+	mergeKeepTarget = "target"
+	mergeTakeSource = "source"
+)
+
+var mergeableFields = []string{"firstName", "lastName", "company", "email", "phone"}
+
+const duplicateCandidatesDDL = `
+CREATE TABLE IF NOT EXISTS duplicate_candidates (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  contact_a   BIGINT NOT NULL,
+  contact_b   BIGINT NOT NULL,
+  score       DOUBLE NOT NULL,
+  signals     TEXT   NOT NULL,
+  status      VARCHAR(16) NOT NULL DEFAULT 'open',
+  detected_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_pair (contact_a, contact_b),
+  INDEX idx_status_score (status, score),
+  FOREIGN KEY (contact_a) REFERENCES contacts(id) ON DELETE CASCADE,
+  FOREIGN KEY (contact_b) REFERENCES contacts(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// contact_merges deliberately has no FK on source_id: the source row is deleted by the merge.
+const contactMergesDDL = `
+CREATE TABLE IF NOT EXISTS contact_merges (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  target_id BIGINT NOT NULL,
+  source_id BIGINT NOT NULL,
+  choices   TEXT NOT NULL,
+  source    TEXT NOT NULL,
+  result    TEXT NOT NULL,
+  merged_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_target (target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// duplicateScanner tracks the state of the background duplicate-finding job
+// so that overlapping runs are skipped and the last outcome can be reported.
+type duplicateScanner struct {
+	mu        sync.Mutex
+	running   bool
+	lastRun   time.Time
+	lastPairs int
+	lastErr   error
+}
+
+var dupScanner duplicateScanner
+
+// runDuplicateScanner periodically rescans all contacts. interval <= 0 disables it.
+func runDuplicateScanner(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			dupScanner.run(ctx, defaultDuplicateThreshold)
+		}
+	}
+}
+
+// run performs one scan; it returns false if a scan was already in progress.
+func (s *duplicateScanner) run(ctx context.Context, threshold float64) bool {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return false
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	n, err := scanDuplicates(ctx, threshold)
+	if err != nil {
+		slog.Error("duplicate scan", "err", err)
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.lastRun = time.Now().UTC()
+	s.lastPairs = n
+	s.lastErr = err
+	s.mu.Unlock()
+	return true
+}
+
+func (s *duplicateScanner) status() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]any{"running": s.running, "pairsFound": s.lastPairs}
+	if !s.lastRun.IsZero() {
+		out["lastRun"] = s.lastRun
+	}
+	if s.lastErr != nil {
+		out["lastError"] = s.lastErr.Error()
+	}
+	return out
+}
+
+// startDuplicateScan triggers a scan in the background.
+func startDuplicateScan(w http.ResponseWriter, r *http.Request) {
+	threshold := defaultDuplicateThreshold
+	if s := r.URL.Query().Get("threshold"); s != "" {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t <= 0 || t > 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid threshold: %q", s))
+			return
+		}
+		threshold = t
+	}
+	dupScanner.mu.Lock()
+	running := dupScanner.running
+	dupScanner.mu.Unlock()
+	if running {
+		writeError(w, http.StatusConflict, fmt.Errorf("duplicate scan already running"))
+		return
+	}
+	// The scan outlives this request; it stops when the server shuts down.
+	go dupScanner.run(shutdownCtx, threshold)
+	writeJSON(w, http.StatusAccepted, dupScanner.status())
+}
+
+func duplicateScanStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dupScanner.status())
+}
+
+// listDuplicates returns open candidate pairs, best matches first.
+func listDuplicates(w http.ResponseWriter, r *http.Request) {
+	page := parseIntDefault(r.URL.Query().Get("page"), 1)
+	pageSize := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("pageSize"), 0))
+	if page < 1 {
+		page = 1
+	}
+	minScore := defaultDuplicateThreshold
+	if s := r.URL.Query().Get("minScore"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid minScore: %q", s))
+			return
+		}
+		minScore = v
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = duplicateOpen
+	}
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT d.id, d.score, d.signals, d.status, d.detected_at,
+       a.id, a.first_name, a.last_name, a.company, a.email, a.phone, a.created_at, a.updated_at,
+       b.id, b.first_name, b.last_name, b.company, b.email, b.phone, b.created_at, b.updated_at
+FROM duplicate_candidates d
+JOIN contacts a ON a.id = d.contact_a
+JOIN contacts b ON b.id = d.contact_b
+WHERE d.status = ? AND d.score >= ?
+ORDER BY d.score DESC, d.id
+LIMIT ? OFFSET ?`, status, minScore, pageSize, (page-1)*pageSize)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []DuplicateCandidate{}
+	for rows.Next() {
+		var d DuplicateCandidate
+		var signals string
+		var aCompany, aPhone, bCompany, bPhone sql.NullString
+		a, b := &d.ContactA, &d.ContactB
+		if err := rows.Scan(&d.ID, &d.Score, &signals, &d.Status, &d.DetectedAt,
+			&a.ID, &a.FirstName, &a.LastName, &aCompany, &a.Email, &aPhone, &a.CreatedAt, &a.UpdatedAt,
+			&b.ID, &b.FirstName, &b.LastName, &bCompany, &b.Email, &bPhone, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if aCompany.Valid {
+			a.Company = &aCompany.String
+		}
+		if aPhone.Valid {
+			a.Phone = &aPhone.String
+		}
+		if bCompany.Valid {
+			b.Company = &bCompany.String
+		}
+		if bPhone.Valid {
+			b.Phone = &bPhone.String
+		}
+		_ = json.Unmarshal([]byte(signals), &d.Signals)
+		items = append(items, d)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    renderDuplicates(r, items),
+	})
+}
+
+// dismissDuplicate marks a candidate pair as not-a-duplicate; rescans keep it dismissed.
+func dismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "pairId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `UPDATE duplicate_candidates SET status = ? WHERE id = ?`, duplicateDismissed, id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("duplicate pair %d not found", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeContact folds the source contact into the target ({id}) and deletes the
+// source. Everything happens in one transaction and is recorded in contact_merges.
+func mergeContact(w http.ResponseWriter, r *http.Request) {
+	targetID, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in MergeInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if in.SourceID <= 0 {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("sourceId is required"))
+		return
+	}
+	if in.SourceID == targetID {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("cannot merge a contact into itself"))
+		return
+	}
+	// The merge rewrites the target and deletes the source, so neither may
+	// be under someone else's edit lock.
+	if rejectIfLocked(w, r, targetID) || rejectIfLocked(w, r, in.SourceID) {
+		return
+	}
+	for name, choice := range in.Fields {
+		if !slices.Contains(mergeableFields, name) {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("unknown field %q", name))
+			return
+		}
+		if choice != mergeKeepTarget && choice != mergeTakeSource {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("fields.%s must be %q or %q", name, mergeKeepTarget, mergeTakeSource))
+			return
+		}
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	defs, err := loadCustomFields(ctx, db)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	byName := fieldsByName(defs)
+	for name, choice := range in.CustomFields {
+		if _, ok := byName[name]; !ok {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("unknown custom field %q", name))
+			return
+		}
+		if choice != mergeKeepTarget && choice != mergeTakeSource {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("customFields.%s must be %q or %q", name, mergeKeepTarget, mergeTakeSource))
+			return
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock in id order, so that merges of the same pair in opposite
+	// directions wait for each other instead of deadlocking.
+	locked := make(map[int64]Contact, 2)
+	for _, id := range []int64{min(targetID, in.SourceID), max(targetID, in.SourceID)} {
+		c, err := lockContact(ctx, tx, defs, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		locked[id] = c
+	}
+	target, source := locked[targetID], locked[in.SourceID]
+
+	m, err := applyMerge(ctx, tx, defs, target, source, in)
+	if errors.Is(err, errMergeInvalid) {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	merged, err := loadContact(ctx, tx, targetID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if err := recordContactEvent(ctx, tx, eventContactDeleted, source.ID, source); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if err := recordContactEvent(ctx, tx, eventContactUpdated, targetID, merged); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	notifyEventsCommitted()
+	contactsMerged.Inc()
+	writeJSON(w, http.StatusOK, renderMerge(r, m))
+}
+
+var errMergeInvalid = errors.New("invalid merge")
+
+// moveRelationships re-points the source's relationships at the target.
+// Those between the two would become self-edges and are dropped, as are
+// those the target already has, in either direction for bidirectional ones.
+func moveRelationships(ctx context.Context, tx *sql.Tx, targetID, sourceID int64) error {
+	edges, err := loadRelationships(ctx, tx, []int64{targetID, sourceID})
+	if err != nil {
+		return err
+	}
+	type edgeKey struct {
+		from, to int64
+		typ      string
+	}
+	has := make(map[edgeKey]bool)
+	add := func(e Relationship) {
+		has[edgeKey{e.FromID, e.ToID, e.Type}] = true
+		if e.Bidirectional {
+			has[edgeKey{e.ToID, e.FromID, e.Type}] = true
+		}
+	}
+	var moving []Relationship
+	for _, e := range edges {
+		if e.FromID == sourceID || e.ToID == sourceID {
+			moving = append(moving, e)
+		} else {
+			add(e)
+		}
+	}
+	for _, e := range moving {
+		if e.FromID == sourceID {
+			e.FromID = targetID
+		}
+		if e.ToID == sourceID {
+			e.ToID = targetID
+		}
+		dup := has[edgeKey{e.FromID, e.ToID, e.Type}] || e.Bidirectional && has[edgeKey{e.ToID, e.FromID, e.Type}]
+		if e.FromID == e.ToID || dup {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM contact_relationships WHERE id = ?`, e.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE contact_relationships SET from_id = ?, to_id = ? WHERE id = ?`, e.FromID, e.ToID, e.ID); err != nil {
+			return err
+		}
+		add(e)
+	}
+	return nil
+}
+
+// applyMerge resolves every field, writes the merged target, moves the
+// source's notes, reminders and relationships to it, deletes the source and
+// records the audit row. The caller owns tx and commits on success.
+func applyMerge(ctx context.Context, tx *sql.Tx, defs []CustomField, target, source Contact, in MergeInput) (ContactMerge, error) {
+	choices := make(map[string]string, len(mergeableFields))
+	pick := func(name string, targetEmpty bool) bool {
+		choice, ok := in.Fields[name]
+		if !ok {
+			choice = mergeKeepTarget
+			if targetEmpty {
+				choice = mergeTakeSource
+			}
+		}
+		choices[name] = choice
+		return choice == mergeTakeSource
+	}
+	if pick("firstName", strings.TrimSpace(target.FirstName) == "") {
+		target.FirstName = source.FirstName
+	}
+	if pick("lastName", strings.TrimSpace(target.LastName) == "") {
+		target.LastName = source.LastName
+	}
+	if pick("company", target.Company == nil) {
+		target.Company = source.Company
+	}
+	if pick("email", target.Email == "") {
+		target.Email = source.Email
+	}
+	if pick("phone", target.Phone == nil) {
+		target.Phone = source.Phone
+	}
+
+	custom := make(map[string]*string)
+	customChoices := make(map[string]string)
+	for _, f := range defs {
+		sv, inSource := source.CustomFields[f.Name]
+		_, inTarget := target.CustomFields[f.Name]
+		choice, ok := in.CustomFields[f.Name]
+		if !ok {
+			choice = mergeKeepTarget
+			if !inTarget && inSource {
+				choice = mergeTakeSource
+			}
+		}
+		if !inTarget && !inSource {
+			continue
+		}
+		customChoices[f.Name] = choice
+		if choice != mergeTakeSource {
+			continue
+		}
+		if !inSource {
+			if f.Required {
+				return ContactMerge{}, fmt.Errorf("%w: customFields.%s is required", errMergeInvalid, f.Name)
+			}
+			custom[f.Name] = nil
+			delete(target.CustomFields, f.Name)
+			continue
+		}
+		v := formatFieldValue(sv)
+		custom[f.Name] = &v
+		if target.CustomFields == nil {
+			target.CustomFields = make(map[string]any)
+		}
+		target.CustomFields[f.Name] = sv
+	}
+
+	sourceJSON, err := json.Marshal(source)
+	if err != nil {
+		return ContactMerge{}, err
+	}
+	choicesJSON, err := json.Marshal(map[string]any{"fields": choices, "customFields": customChoices})
+	if err != nil {
+		return ContactMerge{}, err
+	}
+
+	// Deleting the source cascades to everything that references it, so
+	// first hand its history to the target.
+	for _, table := range []string{"contact_notes", "contact_reminders"} {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET contact_id = ? WHERE contact_id = ?`, target.ID, source.ID); err != nil {
+			return ContactMerge{}, err
+		}
+	}
+	if err := moveRelationships(ctx, tx, target.ID, source.ID); err != nil {
+		return ContactMerge{}, err
+	}
+	// Delete the source before the update so that taking its email does not
+	// trip the UNIQUE index.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM contacts WHERE id = ?`, source.ID); err != nil {
+		return ContactMerge{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if _, err := tx.ExecContext(ctx, `
+UPDATE contacts
+SET first_name = ?, last_name = ?, company = ?, email = ?, phone = ?, updated_at = ?
+WHERE id = ?`,
+		target.FirstName, target.LastName, nullable(target.Company), target.Email, nullable(target.Phone), now, target.ID); err != nil {
+		return ContactMerge{}, err
+	}
+	if err := saveCustomValues(ctx, tx, target.ID, defs, custom, false); err != nil {
+		return ContactMerge{}, err
+	}
+	target.UpdatedAt = now
+
+	resultJSON, err := json.Marshal(target)
+	if err != nil {
+		return ContactMerge{}, err
+	}
+	mergeID, err := insertID(ctx, tx, `
+INSERT INTO contact_merges (target_id, source_id, choices, source, result, merged_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		target.ID, source.ID, string(choicesJSON), string(sourceJSON), string(resultJSON), now)
+	if err != nil {
+		return ContactMerge{}, err
+	}
+
+	return ContactMerge{
+		ID:       mergeID,
+		TargetID: target.ID,
+		SourceID: source.ID,
+		Choices:  choicesJSON,
+		Source:   sourceJSON,
+		Result:   resultJSON,
+		MergedAt: now,
+	}, nil
+}
+
+// listContactMerges returns the merge audit trail for a surviving contact.
+func listContactMerges(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT id, target_id, source_id, choices, source, result, merged_at
+FROM contact_merges
+WHERE target_id = ?
+ORDER BY id`, id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []ContactMerge{}
+	for rows.Next() {
+		var m ContactMerge
+		var choices, source, result string
+		if err := rows.Scan(&m.ID, &m.TargetID, &m.SourceID, &choices, &source, &result, &m.MergedAt); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		m.Choices = json.RawMessage(choices)
+		m.Source = json.RawMessage(source)
+		m.Result = json.RawMessage(result)
+		items = append(items, m)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": renderMerges(r, items)})
+}
+
+// lockContact reads a contact and its custom values with a row lock held until tx ends.
+func lockContact(ctx context.Context, tx *sql.Tx, defs []CustomField, id int64) (Contact, error) {
+	c, err := scanContact(stmts.queryRow(ctx, tx, sqlLockContact, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, fmt.Errorf("contact %d not found: %w", id, err)
+	}
+	if err != nil {
+		return c, err
+	}
+
+	byID := make(map[int64]CustomField, len(defs))
+	for _, f := range defs {
+		byID[f.ID] = f
+	}
+	rows, err := stmts.query(ctx, tx, sqlContactFieldValues, id)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fieldID int64
+		var value string
+		if err := rows.Scan(&fieldID, &value); err != nil {
+			return c, err
+		}
+		if f, ok := byID[fieldID]; ok {
+			if c.CustomFields == nil {
+				c.CustomFields = make(map[string]any)
+			}
+			c.CustomFields[f.Name] = f.decode(value)
+		}
+	}
+	return c, rows.Err()
+}
+
+// Scanning
+
+// scanDuplicates scores candidate pairs and upserts those above threshold.
+// Pairs are only compared when they share a blocking key, which keeps the
+// job well below O(n^2) on realistic data. Each statement gets
+// db.queryTimeout; the scan as a whole runs until ctx ends.
+func scanDuplicates(ctx context.Context, threshold float64) (int, error) {
+	loadCtx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := stmts.query(loadCtx, db, sqlAllContacts)
+	if err != nil {
+		return 0, err
+	}
+	all, err := scanContacts(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	blocks := make(map[string][]int)
+	for i, c := range all {
+		for _, k := range blockingKeys(c) {
+			blocks[k] = append(blocks[k], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+	seen := make(map[pair]bool)
+	found := 0
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, members := range blocks {
+		for i := 0; i < len(members); i++ {
+			for j := i + 1; j < len(members); j++ {
+				p := pair{members[i], members[j]}
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				a, b := all[p.a], all[p.b]
+				score, signals := scorePair(a, b)
+				if score < threshold {
+					continue
+				}
+				if err := saveDuplicateCandidate(ctx, a.ID, b.ID, score, signals, now); err != nil {
+					return found, err
+				}
+				found++
+			}
+		}
+	}
+	return found, nil
+}
+
+// saveDuplicateCandidate upserts a scored pair.
+func saveDuplicateCandidate(ctx context.Context, a, b int64, score float64, signals map[string]float64, now time.Time) error {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	sig, _ := json.Marshal(signals)
+	_, err := db.ExecContext(ctx, dialect.upsert(`
+INSERT INTO duplicate_candidates (contact_a, contact_b, score, signals, status, detected_at)
+VALUES (?, ?, ?, ?, ?, ?)`, []string{"contact_a", "contact_b"}, "score", "signals", "detected_at"),
+		a, b, score, string(sig), duplicateOpen, now)
+	return err
+}
+
+// blockingKeys returns the buckets a contact is compared within.
+func blockingKeys(c Contact) []string {
+	var keys []string
+	if last, ok := runePrefix(foldName(c.LastName), 2); ok {
+		keys = append(keys, "ln:"+last)
+	}
+	if first, ok := runePrefix(foldName(c.FirstName), 2); ok {
+		keys = append(keys, "fn:"+first)
+	}
+	if p := normalizePhone(c.Phone); p != "" {
+		keys = append(keys, "ph:"+p)
+	}
+	if local, _ := normalizeEmail(c.Email); local != "" {
+		keys = append(keys, "em:"+local)
+	}
+	return keys
+}
+
+// runePrefix returns the first n runes of s, and false if s is shorter.
+// Names are often not ASCII, and a byte prefix would split a character.
+func runePrefix(s string, n int) (string, bool) {
+	for i := range s {
+		if n == 0 {
+			return s[:i], true
+		}
+		n--
+	}
+	return s, n == 0
+}
+
+// scorePair returns a weighted similarity in [0,1] plus the individual signals.
+func scorePair(a, b Contact) (float64, map[string]float64) {
+	signals := make(map[string]float64, 3)
+	var total, weight float64
+
+	name := jaroWinkler(foldName(a.FirstName+" "+a.LastName), foldName(b.FirstName+" "+b.LastName))
+	// Also try swapped first/last, a common data-entry mistake.
+	if swapped := jaroWinkler(foldName(a.FirstName+" "+a.LastName), foldName(b.LastName+" "+b.FirstName)); swapped > name {
+		name = swapped
+	}
+	signals["name"] = round2(name)
+	total += name * nameWeight
+	weight += nameWeight
+
+	email := emailSimilarity(a.Email, b.Email)
+	signals["email"] = round2(email)
+	total += email * emailWeight
+	weight += emailWeight
+
+	if pa, pb := normalizePhone(a.Phone), normalizePhone(b.Phone); pa != "" && pb != "" {
+		phone := 0.0
+		if pa == pb {
+			phone = 1
+		}
+		signals["phone"] = phone
+		total += phone * phoneWeight
+		weight += phoneWeight
+	}
+
+	return round2(total / weight), signals
+}
+
+func emailSimilarity(a, b string) float64 {
+	localA, domainA := normalizeEmail(a)
+	localB, domainB := normalizeEmail(b)
+	if localA == "" || localB == "" {
+		return 0
+	}
+	if localA == localB && domainA == domainB {
+		return 1
+	}
+	s := jaroWinkler(localA, localB)
+	if domainA != domainB {
+		s *= 0.8
+	}
+	return s
+}
+
+// normalizeEmail lowercases and strips +tags and dots from the local part.
+func normalizeEmail(s string) (local, domain string) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return "", ""
+	}
+	local, domain = s[:at], s[at+1:]
+	if i := strings.IndexByte(local, '+'); i >= 0 {
+		local = local[:i]
+	}
+	return strings.ReplaceAll(local, ".", ""), domain
+}
+
+// normalizePhone keeps digits only and compares on the last ten, so that
+// "+1 (555) 010-0100" and "555.010.0100" match.
+func normalizePhone(p *string) string {
+	if p == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range *p {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	d := b.String()
+	if len(d) > 10 {
+		d = d[len(d)-10:]
+	}
+	if len(d) < 7 {
+		return ""
+	}
+	return d
+}
+
+func foldName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || r == ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b in [0,1].
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	ma := make([]bool, len(ra))
+	mb := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !mb[j] && ra[i] == rb[j] {
+				ma[i], mb[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, k := 0, 0
+	for i := range ra {
+		if !ma[i] {
+			continue
+		}
+		for !mb[k] {
+			k++
+		}
+		if ra[i] != rb[k] {
+			transpositions++
+		}
+		k++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for i := 0; i < min(4, len(ra), len(rb)) && ra[i] == rb[i]; i++ {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func round2(f float64) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'f', 2, 64), 64)
+	return v
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"my-go-api/client"
+
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+func createTestContact(t testing.TB, h http.Handler, first, email string) int64 {
+	t.Helper()
+	var c Contact
+	in := client.ContactInput{FirstName: first, LastName: "Test", Email: openapi_types.Email(email)}
+	if status := do(t, h, http.MethodPost, "/contacts", in, &c); status != http.StatusCreated {
+		t.Fatalf("create %s: status %d", email, status)
+	}
+	return c.ID
+}
+
+func TestMergeKeepsNotesRemindersAndRelationships(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		target := createTestContact(t, h, "Ada", "ada@example.com")
+		source := createTestContact(t, h, "Ada", "ada.l@example.com")
+		other := createTestContact(t, h, "Grace", "grace@example.com")
+
+		post := func(path string, body any) {
+			t.Helper()
+			if status := do(t, h, http.MethodPost, path, body, nil); status != http.StatusCreated {
+				t.Fatalf("POST %s: status %d", path, status)
+			}
+		}
+		post(fmt.Sprintf("/contacts/%d/notes", source), client.NoteInput{Kind: client.NoteInputKindCall, Author: "sam", Body: "Called about renewal"})
+		post(fmt.Sprintf("/contacts/%d/reminders", source), client.ReminderInput{Title: "Follow up", DueIn: ptr("2w")})
+		// Moves to target -> other, which target already has: dropped.
+		post(fmt.Sprintf("/contacts/%d/relationships", source), client.RelationshipInput{ToId: other, Type: client.ReportsTo})
+		post(fmt.Sprintf("/contacts/%d/relationships", target), client.RelationshipInput{ToId: other, Type: client.ReportsTo})
+		// Would become target -> target: dropped.
+		post(fmt.Sprintf("/contacts/%d/relationships", source), client.RelationshipInput{ToId: target, Type: client.ColleagueOf, Bidirectional: ptr(true)})
+		// Moves to other -> target.
+		post(fmt.Sprintf("/contacts/%d/relationships", other), client.RelationshipInput{ToId: source, Type: client.ReferredBy})
+
+		if status := do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/merge", target), client.MergeInput{SourceId: source}, nil); status != http.StatusOK {
+			t.Fatalf("merge: status %d", status)
+		}
+
+		var timeline struct{ Items []Note }
+		do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d/timeline", target), nil, &timeline)
+		if len(timeline.Items) != 1 || timeline.Items[0].Body != "Called about renewal" {
+			t.Errorf("target timeline = %+v, want the source's note", timeline.Items)
+		}
+		var reminders struct{ Items []Reminder }
+		do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d/reminders", target), nil, &reminders)
+		if len(reminders.Items) != 1 || reminders.Items[0].Title != "Follow up" {
+			t.Errorf("target reminders = %+v, want the source's reminder", reminders.Items)
+		}
+		var rels struct{ Items []RelatedContact }
+		do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d/relationships", target), nil, &rels)
+		var got []string
+		for _, rc := range rels.Items {
+			got = append(got, fmt.Sprintf("%s %s %d", rc.Direction, rc.Type, rc.ContactID))
+		}
+		sort.Strings(got)
+		want := []string{
+			fmt.Sprintf("in referred %d", other),
+			fmt.Sprintf("out reports_to %d", other),
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("target relationships = %v, want %v", got, want)
+		}
+		if status := do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d", source), nil, nil); status != http.StatusNotFound {
+			t.Errorf("source after merge: status %d, want 404", status)
+		}
+	})
+}
+
+func TestMergeRespectsEditLocks(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		old := collab
+		collab = &collabHub{rooms: make(map[int64]*collabRoom)}
+		t.Cleanup(func() { collab = old })
+		h := testRouter()
+		target := createTestContact(t, h, "Ada", "ada@example.com")
+		source := createTestContact(t, h, "Ada", "ada.l@example.com")
+		merge := func() int {
+			return do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/merge", target), client.MergeInput{SourceId: source}, nil)
+		}
+
+		for name, id := range map[string]int64{"target": target, "source": source} {
+			grace := joinTestRoom(collab, id, "grace")
+			collab.handle(id, grace, collabMessage{Type: "lock"})
+			if status := merge(); status != http.StatusLocked {
+				t.Errorf("%s locked: status %d, want 423", name, status)
+			}
+			collab.handle(id, grace, collabMessage{Type: "unlock"})
+		}
+		if status := merge(); status != http.StatusOK {
+			t.Errorf("unlocked: status %d, want 200", status)
+		}
+	})
+}
+
+// TestConcurrentOppositeMerges merges two contacts into each other at once.
+// With row locks one merge wins and the other finds its source gone; either
+// way neither fails on a deadlock.
+func TestConcurrentOppositeMerges(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		for i := range 5 {
+			a := createTestContact(t, h, "Ada", fmt.Sprintf("ada%d@example.com", i))
+			b := createTestContact(t, h, "Ada", fmt.Sprintf("ada.l%d@example.com", i))
+			var wg sync.WaitGroup
+			statuses := make([]int, 2)
+			for j, pair := range [][2]int64{{a, b}, {b, a}} {
+				wg.Go(func() {
+					statuses[j] = do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/merge", pair[0]), client.MergeInput{SourceId: pair[1]}, nil)
+				})
+			}
+			wg.Wait()
+			slices.Sort(statuses)
+			if statuses[0] != http.StatusOK || statuses[1] != http.StatusOK && statuses[1] != http.StatusNotFound {
+				t.Errorf("round %d: statuses %v, want a 200 and a 200 or 404", i+1, statuses)
+			}
+		}
+	})
+}
+
+func TestBlockingKeys(t *testing.T) {
+	for _, tt := range []struct {
+		first, last string
+		want        []string
+	}{
+		{"Ada", "Lovelace", []string{"ln:lo", "fn:ad", "em:x"}},
+		{"Émile", "Zoë", []string{"ln:zo", "fn:ém", "em:x"}},
+		{"明", "李小", []string{"ln:李小", "em:x"}},
+		{"Ł", "Ωm", []string{"ln:ωm", "em:x"}},
+	} {
+		got := blockingKeys(Contact{FirstName: tt.first, LastName: tt.last, Email: "x@example.com"})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("blockingKeys(%s %s) = %q, want %q", tt.first, tt.last, got, tt.want)
+		}
+	}
+}
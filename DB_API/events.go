@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Contact lifecycle event types.
+const (
+	eventContactCreated = "contact.created"
+	eventContactUpdated = "contact.updated"
+	eventContactDeleted = "contact.deleted"
+)
+
+var contactEventTypes = []string{eventContactCreated, eventContactUpdated, eventContactDeleted}
+
+// Event is the envelope delivered to sinks and webhook subscribers. Data is
+// the contact as returned by the REST API; for deletions it is the contact's
+// last state before it was removed. ID doubles
+// as the idempotency key: a relay retry re-sends the same ID.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	ContactID  int64           `json:"contactId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+func newEvent(typ string, contactID int64, data any) (Event, error) {
+	evt := Event{
+		ID:         newEventID(),
+		Type:       typ,
+		ContactID:  contactID,
+		OccurredAt: time.Now().UTC(),
+	}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return evt, err
+		}
+		evt.Data = b
+	}
+	return evt, nil
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// notifyEventsCommitted wakes everything that tails the outbox. Call it after
+// a transaction that recorded events has committed.
+func notifyEventsCommitted() {
+	relay.notify()
+	eventHub.notify()
+}
@@ -0,0 +1,699 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// GraphQL schema over the contacts domain. Mutations go through the same
+// insertContact/applyContactPatch/removeContact paths as the REST handlers,
+// so validation, outbox events and edit locks behave identically.
+const graphqlSchema = `
+schema {
+  query: Query
+  mutation: Mutation
+  subscription: Subscription
+}
+
+scalar Time
+
+# A JSON object, used for custom field values.
+scalar JSON
+
+type Query {
+  contact(id: ID!): Contact
+  contacts(filter: ContactFilter, sort: ContactSort, page: Int = 1, pageSize: Int = 50): ContactPage!
+}
+
+type Mutation {
+  createContact(input: ContactInput!): Contact!
+  # Only the fields that are set are changed; a null custom field value clears it.
+  updateContact(id: ID!, input: ContactPatch!): Contact!
+  # Returns the contact as it was before deletion.
+  deleteContact(id: ID!): Contact!
+}
+
+type Subscription {
+  contactChanged(types: [ContactEventType!], contactId: ID): ContactEvent!
+}
+
+input ContactFilter {
+  # Substring match on first name, last name or email.
+  search: String
+  company: String
+  email: String
+  # Exact match on custom field values, e.g. {"tier": "gold"}.
+  customFields: JSON
+}
+
+enum ContactSortField { ID FIRST_NAME LAST_NAME EMAIL COMPANY CREATED_AT UPDATED_AT }
+enum SortDirection { ASC DESC }
+
+input ContactSort {
+  field: ContactSortField!
+  direction: SortDirection = ASC
+}
+
+type ContactPage {
+  items: [Contact!]!
+  page: Int!
+  pageSize: Int!
+  totalCount: Int!
+  hasNextPage: Boolean!
+}
+
+type Contact {
+  id: ID!
+  firstName: String!
+  lastName: String!
+  company: String
+  email: String!
+  phone: String
+  createdAt: Time!
+  updatedAt: Time!
+  customFields: JSON
+  customField(name: String!): String
+  notes(first: Int = 10): [Note!]!
+  relationships: [RelatedContact!]!
+}
+
+type Note {
+  id: ID!
+  kind: String!
+  author: String!
+  subject: String
+  body: String!
+  occurredAt: Time!
+}
+
+type RelatedContact {
+  relationshipId: ID!
+  type: String!
+  direction: String!
+  contact: Contact
+}
+
+enum ContactEventType { CREATED UPDATED DELETED }
+
+type ContactEvent {
+  id: String!
+  type: ContactEventType!
+  contactId: ID!
+  occurredAt: Time!
+  # The contact after the change; for deletions, its last state.
+  contact: Contact
+}
+
+input ContactInput {
+  firstName: String!
+  lastName: String!
+  company: String
+  email: String!
+  phone: String
+  customFields: JSON
+}
+
+input ContactPatch {
+  firstName: String
+  lastName: String
+  company: String
+  email: String
+  phone: String
+  customFields: JSON
+}
+`
+
+// Query limits. Depth is enforced by the library during validation;
+// complexity is estimated by the root resolvers from the selected fields,
+// multiplying each field by the size of the lists it is nested in.
+const (
+	defaultGraphQLMaxDepth      = 8
+	defaultGraphQLMaxComplexity = 10000
+	graphqlMaxQueryBytes        = 16 << 10
+	graphqlMaxNotes             = 100
+	// graphqlRelationshipFanout is the assumed size of a relationships list.
+	graphqlRelationshipFanout = 10
+)
+
+var gqlSchema *graphql.Schema
+
+var gqlEventTypes = map[string]string{
+	"CREATED": eventContactCreated,
+	"UPDATED": eventContactUpdated,
+	"DELETED": eventContactDeleted,
+}
+
+var gqlSortColumns = map[string]string{
+	"ID":         "id",
+	"FIRST_NAME": "first_name",
+	"LAST_NAME":  "last_name",
+	"EMAIL":      "email",
+	"COMPANY":    "company",
+	"CREATED_AT": "created_at",
+	"UPDATED_AT": "updated_at",
+}
+
+// initGraphQL parses the schema with the graphql.maxDepth limit. The
+// complexity limit is checked per query, so it can be reloaded.
+func initGraphQL(maxDepth int) error {
+	s, err := graphql.ParseSchema(graphqlSchema, &gqlResolver{},
+		graphql.MaxDepth(maxDepth),
+		graphql.MaxQueryLength(graphqlMaxQueryBytes),
+	)
+	if err != nil {
+		return fmt.Errorf("graphql schema: %w", err)
+	}
+	gqlSchema = s
+	return nil
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type gqlLockTokenKey struct{}
+
+// serveGraphQL handles POST /graphql, and GET /graphql upgraded to a
+// WebSocket for subscriptions (see graphql_ws.go).
+func serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		serveGraphQLWS(w, r)
+		return
+	}
+	var req graphqlRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("query is required"))
+		return
+	}
+	resp := gqlSchema.Exec(graphqlContext(r), req.Query, req.OperationName, req.Variables)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// graphqlContext carries the X-Lock-Token header through to the mutation
+// resolvers for edit lock checks.
+func graphqlContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), gqlLockTokenKey{}, r.Header.Get(headerLockToken))
+}
+
+// gqlError adds an extensions.code to errors returned from resolvers.
+type gqlError struct {
+	err  error
+	code string
+}
+
+func (e *gqlError) Error() string { return e.err.Error() }
+func (e *gqlError) Unwrap() error { return e.err }
+
+func (e *gqlError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func toGQLError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code := "INTERNAL"
+	switch errStatus(err) {
+	case http.StatusBadRequest:
+		code = "BAD_USER_INPUT"
+	case http.StatusNotFound:
+		code = "NOT_FOUND"
+	case http.StatusConflict:
+		code = "CONFLICT"
+	case http.StatusUnprocessableEntity:
+		code = "VALIDATION_FAILED"
+	case http.StatusLocked:
+		code = "LOCKED"
+	case http.StatusGatewayTimeout:
+		code, err = "TIMEOUT", errTimeout
+	case http.StatusServiceUnavailable:
+		code, err = "UNAVAILABLE", errUnavailable
+	}
+	return &gqlError{err: err, code: code}
+}
+
+// checkComplexity estimates the cost of the selection below the current root
+// field. pageSize is the length of a page's items list.
+func checkComplexity(ctx context.Context, pageSize int) error {
+	limit := cfg().GraphQL.MaxComplexity
+	cost := 1
+	for _, path := range graphql.SelectedFieldNames(ctx) {
+		weight := 1
+		segments := strings.Split(path, ".")
+		for i := range len(segments) - 1 {
+			weight *= listFanout(ctx, strings.Join(segments[:i+1], "."), segments[i], pageSize)
+		}
+		cost += weight
+		if cost > limit {
+			return toGQLError(statusErr(http.StatusBadRequest,
+				fmt.Errorf("query is too complex: exceeds the limit of %d", limit)))
+		}
+	}
+	return nil
+}
+
+func listFanout(ctx context.Context, path, field string, pageSize int) int {
+	switch field {
+	case "items":
+		return pageSize
+	case "notes":
+		var args struct{ First *int32 }
+		if ok, _ := graphql.DecodeSelectedFieldArgs(ctx, path, &args); ok && args.First != nil {
+			return clampNotes(*args.First)
+		}
+		return 10
+	case "relationships":
+		return graphqlRelationshipFanout
+	}
+	return 1
+}
+
+func clampNotes(n int32) int {
+	return min(max(int(n), 1), graphqlMaxNotes)
+}
+
+func lockCheck(ctx context.Context, id int64) error {
+	token, _ := ctx.Value(gqlLockTokenKey{}).(string)
+	if _, locked := collab.lockedByOther(id, token); locked {
+		return statusErr(http.StatusLocked, errLockHeld)
+	}
+	return nil
+}
+
+func parseGQLID(id graphql.ID) (int64, error) {
+	n, err := parseIDParam(string(id))
+	if err != nil {
+		return 0, toGQLError(statusErr(http.StatusBadRequest, err))
+	}
+	return n, nil
+}
+
+func gqlID(id int64) graphql.ID {
+	return graphql.ID(fmt.Sprint(id))
+}
+
+// jsonObject is the JSON scalar.
+type jsonObject map[string]any
+
+func (jsonObject) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+func (j *jsonObject) UnmarshalGraphQL(input any) error {
+	m, ok := input.(map[string]any)
+	if !ok {
+		return fmt.Errorf("JSON must be an object, got %T", input)
+	}
+	out := make(jsonObject, len(m))
+	for k, v := range m {
+		// Literals in the query arrive as int32; the REST API sees float64.
+		switch n := v.(type) {
+		case int32:
+			v = float64(n)
+		case int64:
+			v = float64(n)
+		case int:
+			v = float64(n)
+		}
+		out[k] = v
+	}
+	*j = out
+	return nil
+}
+
+func (j jsonObject) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any(j))
+}
+
+// Root resolvers
+
+type gqlResolver struct{}
+
+type contactsArgs struct {
+	Filter   *contactFilterInput
+	Sort     *contactSortInput
+	Page     int32
+	PageSize int32
+}
+
+type contactFilterInput struct {
+	Search       *string
+	Company      *string
+	Email        *string
+	CustomFields *jsonObject
+}
+
+type contactSortInput struct {
+	Field     string
+	Direction string
+}
+
+type contactInputArgs struct {
+	FirstName    string
+	LastName     string
+	Company      *string
+	Email        string
+	Phone        *string
+	CustomFields *jsonObject
+}
+
+type contactPatchArgs struct {
+	FirstName    *string
+	LastName     *string
+	Company      *string
+	Email        *string
+	Phone        *string
+	CustomFields *jsonObject
+}
+
+func (*gqlResolver) Contact(ctx context.Context, args struct{ ID graphql.ID }) (*contactResolver, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	var c Contact
+	err = readReplica(ctx, func(q queryer) (err error) {
+		c, err = loadContact(ctx, q, id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactResolver{c}, nil
+}
+
+func (*gqlResolver) Contacts(ctx context.Context, args contactsArgs) (*contactPageResolver, error) {
+	page, pageSize := int(args.Page), int(args.PageSize)
+	if page < 1 {
+		page = 1
+	}
+	pageSize = pageSizeOrDefault(pageSize)
+	if err := checkComplexity(ctx, pageSize); err != nil {
+		return nil, err
+	}
+	q := contactQuery{}
+	if f := args.Filter; f != nil {
+		if f.Search != nil {
+			q.Search = *f.Search
+		}
+		q.Company, q.Email = f.Company, f.Email
+		if f.CustomFields != nil {
+			q.Filters = make(url.Values, len(*f.CustomFields))
+			for name, v := range *f.CustomFields {
+				q.Filters.Set(customFieldFilterPrefix+name, formatFieldValue(v))
+			}
+		}
+	}
+	if args.Sort != nil {
+		q.SortColumn = gqlSortColumns[args.Sort.Field]
+		q.Descending = args.Sort.Direction == "DESC"
+	}
+	var total int
+	var items []Contact
+	err := readReplica(ctx, func(pool queryer) (err error) {
+		if total, err = countContacts(ctx, pool, q); err != nil {
+			return err
+		}
+		items, err = queryContacts(ctx, pool, q, pageSize, (page-1)*pageSize)
+		return err
+	})
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactPageResolver{items: items, page: page, pageSize: pageSize, total: total}, nil
+}
+
+func (*gqlResolver) CreateContact(ctx context.Context, args struct{ Input contactInputArgs }) (*contactResolver, error) {
+	if err := checkComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	in := ContactInput{
+		FirstName: args.Input.FirstName,
+		LastName:  args.Input.LastName,
+		Company:   args.Input.Company,
+		Email:     args.Input.Email,
+		Phone:     args.Input.Phone,
+	}
+	if args.Input.CustomFields != nil {
+		in.CustomFields = *args.Input.CustomFields
+	}
+	c, err := insertContact(ctx, in)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactResolver{c}, nil
+}
+
+func (*gqlResolver) UpdateContact(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input contactPatchArgs
+}) (*contactResolver, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	if err := lockCheck(ctx, id); err != nil {
+		return nil, toGQLError(err)
+	}
+	in := PartialContact{
+		FirstName: args.Input.FirstName,
+		LastName:  args.Input.LastName,
+		Company:   args.Input.Company,
+		Email:     args.Input.Email,
+		Phone:     args.Input.Phone,
+	}
+	if args.Input.CustomFields != nil {
+		in.CustomFields = *args.Input.CustomFields
+	}
+	c, err := applyContactPatch(ctx, id, in)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactResolver{c}, nil
+}
+
+func (*gqlResolver) DeleteContact(ctx context.Context, args struct{ ID graphql.ID }) (*contactResolver, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	if err := lockCheck(ctx, id); err != nil {
+		return nil, toGQLError(err)
+	}
+	c, err := removeContact(ctx, id)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactResolver{c}, nil
+}
+
+// ContactChanged streams committed contact events from the SSE hub. The
+// channel is closed when the subscription ends or the client falls too far
+// behind.
+func (*gqlResolver) ContactChanged(ctx context.Context, args struct {
+	Types     *[]string
+	ContactID *graphql.ID
+}) (<-chan *contactEventResolver, error) {
+	if err := checkComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	var f eventFilter
+	if args.Types != nil {
+		for _, t := range *args.Types {
+			f.types = append(f.types, gqlEventTypes[t])
+		}
+	}
+	if args.ContactID != nil {
+		id, err := parseGQLID(*args.ContactID)
+		if err != nil {
+			return nil, err
+		}
+		f.contactID = id
+	}
+
+	sub, _, _ := eventHub.subscribe(0, f)
+	out := make(chan *contactEventResolver)
+	go func() {
+		defer close(out)
+		defer eventHub.unsubscribe(sub)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- &contactEventResolver{e}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Object resolvers
+
+type contactPageResolver struct {
+	items          []Contact
+	page, pageSize int
+	total          int
+}
+
+func (p *contactPageResolver) Items() []*contactResolver {
+	out := make([]*contactResolver, len(p.items))
+	for i, c := range p.items {
+		out[i] = &contactResolver{c}
+	}
+	return out
+}
+
+func (p *contactPageResolver) Page() int32       { return int32(p.page) }
+func (p *contactPageResolver) PageSize() int32   { return int32(p.pageSize) }
+func (p *contactPageResolver) TotalCount() int32 { return int32(p.total) }
+func (p *contactPageResolver) HasNextPage() bool { return p.page*p.pageSize < p.total }
+
+type contactResolver struct{ c Contact }
+
+func (r *contactResolver) ID() graphql.ID          { return gqlID(r.c.ID) }
+func (r *contactResolver) FirstName() string       { return r.c.FirstName }
+func (r *contactResolver) LastName() string        { return r.c.LastName }
+func (r *contactResolver) Company() *string        { return r.c.Company }
+func (r *contactResolver) Email() string           { return r.c.Email }
+func (r *contactResolver) Phone() *string          { return r.c.Phone }
+func (r *contactResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.c.CreatedAt} }
+func (r *contactResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.c.UpdatedAt} }
+
+func (r *contactResolver) CustomFields() *jsonObject {
+	if len(r.c.CustomFields) == 0 {
+		return nil
+	}
+	j := jsonObject(r.c.CustomFields)
+	return &j
+}
+
+func (r *contactResolver) CustomField(args struct{ Name string }) *string {
+	v, ok := r.c.CustomFields[args.Name]
+	if !ok {
+		return nil
+	}
+	s := formatFieldValue(v)
+	return &s
+}
+
+func (r *contactResolver) Notes(ctx context.Context, args struct{ First int32 }) ([]*noteResolver, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT `+noteColumns+`
+FROM contact_notes
+WHERE contact_id = ?
+ORDER BY occurred_at DESC, id DESC
+LIMIT ?`, r.c.ID, clampNotes(args.First))
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	defer rows.Close()
+
+	var out []*noteResolver
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, toGQLError(err)
+		}
+		out = append(out, &noteResolver{n})
+	}
+	return out, toGQLError(rows.Err())
+}
+
+func (r *contactResolver) Relationships(ctx context.Context) ([]*relatedContactResolver, error) {
+	edges, err := loadRelationships(ctx, db, []int64{r.c.ID})
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	related := relatedContacts(r.c.ID, edges)
+	out := make([]*relatedContactResolver, len(related))
+	for i, rc := range related {
+		out[i] = &relatedContactResolver{rc}
+	}
+	return out, nil
+}
+
+type noteResolver struct{ n Note }
+
+func (r *noteResolver) ID() graphql.ID           { return gqlID(r.n.ID) }
+func (r *noteResolver) Kind() string             { return r.n.Kind }
+func (r *noteResolver) Author() string           { return r.n.Author }
+func (r *noteResolver) Subject() *string         { return r.n.Subject }
+func (r *noteResolver) Body() string             { return r.n.Body }
+func (r *noteResolver) OccurredAt() graphql.Time { return graphql.Time{Time: r.n.OccurredAt} }
+
+type relatedContactResolver struct{ rc RelatedContact }
+
+func (r *relatedContactResolver) RelationshipID() graphql.ID { return gqlID(r.rc.RelationshipID) }
+func (r *relatedContactResolver) Type() string               { return r.rc.Type }
+func (r *relatedContactResolver) Direction() string          { return r.rc.Direction }
+
+func (r *relatedContactResolver) Contact(ctx context.Context) (*contactResolver, error) {
+	c, err := loadContact(ctx, db, r.rc.ContactID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &contactResolver{c}, nil
+}
+
+type contactEventResolver struct{ e hubEvent }
+
+func (r *contactEventResolver) ID() string            { return r.e.evt.ID }
+func (r *contactEventResolver) ContactID() graphql.ID { return gqlID(r.e.evt.ContactID) }
+func (r *contactEventResolver) OccurredAt() graphql.Time {
+	return graphql.Time{Time: r.e.evt.OccurredAt}
+}
+
+func (r *contactEventResolver) Type() string {
+	for name, typ := range gqlEventTypes {
+		if typ == r.e.evt.Type {
+			return name
+		}
+	}
+	return ""
+}
+
+func (r *contactEventResolver) Contact() *contactResolver {
+	if r.e.contact.ID == 0 {
+		return nil
+	}
+	return &contactResolver{r.e.contact}
+}
+
+var errGraphQLPostOnly = errors.New("use POST for queries and mutations, or a WebSocket for subscriptions")
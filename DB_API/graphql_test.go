@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLLimits(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		// contact, then two levels per relationship, then id: 3 levels is a
+		// depth of 8, the default limit.
+		nested := func(levels int) string {
+			return fmt.Sprintf(`{ contact(id: "%d") { `, id) + strings.Repeat(`relationships { contact { `, levels) + `id` + strings.Repeat(` } }`, levels) + ` } }`
+		}
+		for _, tt := range []struct {
+			name          string
+			query         string
+			maxComplexity int
+			wantErr       string
+		}{
+			{"shallow", nested(3), 0, ""},
+			{"too deep", nested(4), 0, "depth"},
+			{"notes of a page", `{ contacts(pageSize: 200) { items { notes(first: 10) { id } } } }`, 0, ""},
+			{"notes of a page, too many", `{ contacts(pageSize: 200) { items { notes(first: 100) { id } } } }`, 0, "too complex"},
+			{"relationships of a page", `{ contacts(pageSize: 50) { items { relationships { contact { id email } } } } }`, 0, ""},
+			{"reloaded limit", `{ contacts(pageSize: 50) { items { id } } }`, 100, ""},
+			{"reloaded limit, exceeded", `{ contacts(pageSize: 50) { items { id email } } }`, 100, "too complex"},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.maxComplexity > 0 {
+					withConfig(t, func(c *Config) { c.GraphQL.MaxComplexity = tt.maxComplexity })
+				}
+				var resp struct {
+					Errors []struct{ Message string }
+				}
+				if status := do(t, h, http.MethodPost, "/graphql", map[string]any{"query": tt.query}, &resp); status != http.StatusOK {
+					t.Fatalf("status %d", status)
+				}
+				switch {
+				case tt.wantErr == "" && len(resp.Errors) > 0:
+					t.Errorf("errors %+v, want none", resp.Errors)
+				case tt.wantErr != "" && (len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.wantErr)):
+					t.Errorf("errors %+v, want one about %q", resp.Errors, tt.wantErr)
+				}
+			})
+		}
+	})
+}
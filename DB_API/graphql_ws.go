@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// GraphQL over WebSocket using the graphql-transport-ws protocol (the one
+// spoken by the graphql-ws client library). Any operation may be sent, but
+// it exists for subscriptions.
+const (
+	gqlWSProtocol    = "graphql-transport-ws"
+	gqlWSInitTimeout = 10 * time.Second
+	gqlWSMaxOps      = 50
+
+	// Close codes defined by the protocol.
+	gqlWSInvalidMessage  = 4400
+	gqlWSUnauthorized    = 4401
+	gqlWSInitTimeoutCode = 4408
+	gqlWSDuplicateID     = 4409
+	gqlWSTooManyInits    = 4429
+)
+
+type gqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+var gqlUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{gqlWSProtocol},
+	CheckOrigin:     checkWSOrigin,
+}
+
+type gqlWSConn struct {
+	ws  *websocket.Conn
+	wmu sync.Mutex
+
+	mu  sync.Mutex
+	ops map[string]context.CancelFunc
+}
+
+func serveGraphQLWS(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		writeError(w, http.StatusMethodNotAllowed, errGraphQLPostOnly)
+		return
+	}
+	ws, err := gqlUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	if ws.Subprotocol() != gqlWSProtocol {
+		_ = ws.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported subprotocol"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(graphqlContext(r))
+	defer cancel()
+	c := &gqlWSConn{ws: ws, ops: make(map[string]context.CancelFunc)}
+	stop := context.AfterFunc(r.Context(), func() {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+		ws.Close()
+	})
+	defer stop()
+
+	acked := false
+	ws.SetReadLimit(graphqlMaxQueryBytes * 2)
+	_ = ws.SetReadDeadline(time.Now().Add(gqlWSInitTimeout))
+	for {
+		var msg gqlWSMessage
+		if err := ws.ReadJSON(&msg); err != nil {
+			if !acked {
+				c.close(gqlWSInitTimeoutCode, "connection initialisation timeout")
+			}
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			if acked {
+				c.close(gqlWSTooManyInits, "too many initialisation requests")
+				return
+			}
+			acked = true
+			_ = ws.SetReadDeadline(time.Time{})
+			c.write(gqlWSMessage{Type: "connection_ack"})
+		case "ping":
+			c.write(gqlWSMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !acked {
+				c.close(gqlWSUnauthorized, "unauthorized")
+				return
+			}
+			var req graphqlRequest
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+				c.close(gqlWSInvalidMessage, "invalid subscribe message")
+				return
+			}
+			if !c.start(ctx, msg.ID, req) {
+				return
+			}
+		case "complete":
+			c.stop(msg.ID)
+		default:
+			c.close(gqlWSInvalidMessage, "unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// start runs an operation until it completes, the client sends "complete"
+// or the connection closes. It returns false if the connection was closed.
+func (c *gqlWSConn) start(ctx context.Context, id string, req graphqlRequest) bool {
+	c.mu.Lock()
+	if _, dup := c.ops[id]; dup {
+		c.mu.Unlock()
+		c.close(gqlWSDuplicateID, "subscriber for "+id+" already exists")
+		return false
+	}
+	if len(c.ops) >= gqlWSMaxOps {
+		c.mu.Unlock()
+		c.write(gqlWSMessage{ID: id, Type: "error", Payload: gqlErrorPayload("too many operations on this connection")})
+		return true
+	}
+	opCtx, cancel := context.WithCancel(ctx)
+	c.ops[id] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		defer c.stop(id)
+		results, err := gqlSchema.Subscribe(opCtx, req.Query, req.OperationName, req.Variables)
+		if err != nil {
+			c.write(gqlWSMessage{ID: id, Type: "error", Payload: gqlErrorPayload(err.Error())})
+			return
+		}
+		for res := range results {
+			payload, err := json.Marshal(res)
+			if err != nil {
+				slog.Error("graphql ws: marshal result", "err", err)
+				continue
+			}
+			c.write(gqlWSMessage{ID: id, Type: "next", Payload: payload})
+		}
+		if opCtx.Err() == nil {
+			c.write(gqlWSMessage{ID: id, Type: "complete"})
+		}
+	}()
+	return true
+}
+
+func (c *gqlWSConn) stop(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+		delete(c.ops, id)
+	}
+}
+
+func (c *gqlWSConn) write(msg gqlWSMessage) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+	_ = c.ws.WriteJSON(msg)
+}
+
+func (c *gqlWSConn) close(code int, reason string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(collabWriteWait))
+}
+
+func gqlErrorPayload(msg string) json.RawMessage {
+	b, _ := json.Marshal([]map[string]string{{"message": msg}})
+	return b
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"my-go-api/contactspb"
+)
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=my-go-api --go-grpc_out=. --go-grpc_opt=module=my-go-api contacts.proto
+
+// grpcListBatch is how many contacts ListContacts reads per query.
+const grpcListBatch = 200
+
+var grpcSortColumns = map[contactspb.ListContactsRequest_SortField]string{
+	contactspb.ListContactsRequest_ID:         "id",
+	contactspb.ListContactsRequest_FIRST_NAME: "first_name",
+	contactspb.ListContactsRequest_LAST_NAME:  "last_name",
+	contactspb.ListContactsRequest_EMAIL:      "email",
+	contactspb.ListContactsRequest_COMPANY:    "company",
+	contactspb.ListContactsRequest_CREATED_AT: "created_at",
+	contactspb.ListContactsRequest_UPDATED_AT: "updated_at",
+}
+
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(recoverUnaryPanics),
+		grpc.ChainStreamInterceptor(recoverStreamPanics),
+	)
+	contactspb.RegisterContactServiceServer(s, &contactServer{})
+	reflection.Register(s)
+	return s
+}
+
+// serveGRPC runs the ContactService on addr (GRPC_ADDR, default :9090).
+func serveGRPC(s *grpc.Server, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("gRPC ContactService listening", "addr", addr)
+	return s.Serve(lis)
+}
+
+// stopGRPC lets in-flight calls finish until ctx expires, then cuts them
+// off. Watch streams end by themselves once shutdown begins.
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
+	}
+}
+
+// recoverUnaryPanics turns a panic in a unary handler into INTERNAL and logs
+// it with its stack, as recoverPanics does for HTTP.
+func recoverUnaryPanics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = grpcPanic(ctx, info.FullMethod, rec)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStreamPanics is recoverUnaryPanics for streaming handlers.
+func recoverStreamPanics(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = grpcPanic(ss.Context(), info.FullMethod, rec)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func grpcPanic(ctx context.Context, method string, rec any) error {
+	slog.ErrorContext(ctx, "panic", "method", method, "panic", rec, "stack", string(debug.Stack()))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// grpcContactID rejects ids that REST's parseIDParam would, with the same
+// message.
+func grpcContactID(id int64) error {
+	if id <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid id: %q", strconv.FormatInt(id, 10))
+	}
+	return nil
+}
+
+type contactServer struct {
+	contactspb.UnimplementedContactServiceServer
+}
+
+func (*contactServer) GetContact(ctx context.Context, req *contactspb.GetContactRequest) (*contactspb.Contact, error) {
+	if err := grpcContactID(req.GetId()); err != nil {
+		return nil, err
+	}
+	var c Contact
+	err := readReplica(ctx, func(q queryer) (err error) {
+		c, err = loadContact(ctx, q, req.GetId())
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "contact %d not found", req.GetId())
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return contactToProto(c)
+}
+
+func (*contactServer) ListContacts(req *contactspb.ListContactsRequest, stream grpc.ServerStreamingServer[contactspb.Contact]) error {
+	q := contactQuery{
+		Search:     req.GetSearch(),
+		Company:    req.Company,
+		Email:      req.Email,
+		SortColumn: grpcSortColumns[req.GetSort()],
+		Descending: req.GetDescending(),
+	}
+	if len(req.GetCustomFields()) > 0 {
+		q.Filters = make(url.Values, len(req.GetCustomFields()))
+		for name, v := range req.GetCustomFields() {
+			q.Filters.Set(customFieldFilterPrefix+name, v)
+		}
+	}
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	sent := 0
+	for {
+		batch := grpcListBatch
+		if limit > 0 {
+			batch = min(batch, limit-sent)
+		}
+		if batch == 0 {
+			return nil
+		}
+		var items []Contact
+		err := readReplica(stream.Context(), func(pool queryer) (err error) {
+			items, err = queryContacts(stream.Context(), pool, q, batch, sent)
+			return err
+		})
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, c := range items {
+			pc, err := contactToProto(c)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(pc); err != nil {
+				return err
+			}
+		}
+		sent += len(items)
+		if len(items) < batch {
+			return nil
+		}
+	}
+}
+
+func (*contactServer) CreateContact(ctx context.Context, req *contactspb.CreateContactRequest) (*contactspb.Contact, error) {
+	if req.GetContact() == nil {
+		return nil, status.Error(codes.InvalidArgument, "contact is required")
+	}
+	c, err := insertContact(ctx, contactInputFromProto(req.GetContact()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return contactToProto(c)
+}
+
+func (*contactServer) UpdateContact(ctx context.Context, req *contactspb.UpdateContactRequest) (*contactspb.Contact, error) {
+	if err := grpcContactID(req.GetId()); err != nil {
+		return nil, err
+	}
+	if req.GetContact() == nil {
+		return nil, status.Error(codes.InvalidArgument, "contact is required")
+	}
+	if err := grpcLockCheck(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	c, err := replaceContact(ctx, req.GetId(), contactInputFromProto(req.GetContact()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return contactToProto(c)
+}
+
+func (*contactServer) PatchContact(ctx context.Context, req *contactspb.PatchContactRequest) (*contactspb.Contact, error) {
+	if err := grpcContactID(req.GetId()); err != nil {
+		return nil, err
+	}
+	p := req.GetPatch()
+	if p == nil {
+		return nil, status.Error(codes.InvalidArgument, "patch is required")
+	}
+	if err := grpcLockCheck(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	in := PartialContact{
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		Company:   p.Company,
+		Email:     p.Email,
+		Phone:     p.Phone,
+	}
+	if p.GetCustomFields() != nil {
+		in.CustomFields = p.GetCustomFields().AsMap()
+	}
+	c, err := applyContactPatch(ctx, req.GetId(), in)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return contactToProto(c)
+}
+
+func (*contactServer) DeleteContact(ctx context.Context, req *contactspb.DeleteContactRequest) (*contactspb.Contact, error) {
+	if err := grpcContactID(req.GetId()); err != nil {
+		return nil, err
+	}
+	if err := grpcLockCheck(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	c, err := removeContact(ctx, req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return contactToProto(c)
+}
+
+// Watch streams events from the same hub as GET /contacts/events. A client
+// that falls behind is disconnected with UNAVAILABLE and can resume with
+// after_seq.
+func (*contactServer) Watch(req *contactspb.WatchRequest, stream grpc.ServerStreamingServer[contactspb.ContactEvent]) error {
+	f := eventFilter{contactID: req.GetContactId()}
+	for _, t := range req.GetTypes() {
+		if !slices.Contains(contactEventTypes, t) {
+			return status.Errorf(codes.InvalidArgument, "invalid type %q", t)
+		}
+		f.types = append(f.types, t)
+	}
+	sub, replay, truncated := eventHub.subscribe(req.GetAfterSeq(), f)
+	defer eventHub.unsubscribe(sub)
+
+	if truncated {
+		if err := stream.Send(&contactspb.ContactEvent{Type: sseEventReset}); err != nil {
+			return err
+		}
+	}
+	for _, e := range replay {
+		if err := sendWatchEvent(stream, e); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-shutdownCtx.Done():
+			return status.Error(codes.Unavailable, "server shutting down; resume with after_seq")
+		case e, ok := <-sub.ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "watcher fell behind; resume with after_seq")
+			}
+			if err := sendWatchEvent(stream, e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sendWatchEvent(stream grpc.ServerStreamingServer[contactspb.ContactEvent], e hubEvent) error {
+	pe := &contactspb.ContactEvent{
+		Seq:        e.seq,
+		Id:         e.evt.ID,
+		Type:       e.evt.Type,
+		ContactId:  e.evt.ContactID,
+		OccurredAt: timestamppb.New(e.evt.OccurredAt),
+	}
+	if e.contact.ID != 0 {
+		c, err := contactToProto(e.contact)
+		if err != nil {
+			return err
+		}
+		pe.Contact = c
+	}
+	return stream.Send(pe)
+}
+
+// grpcError maps an error from the shared contact operations to the status
+// code matching its REST status.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch errStatus(err) {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusLocked:
+		code = codes.FailedPrecondition
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case statusClientClosedRequest:
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
+
+// grpcLockCheck refuses writes to a contact with an edit lock unless they send
+// its token in x-lock-token metadata, the gRPC equivalent of the X-Lock-Token
+// header.
+func grpcLockCheck(ctx context.Context, id int64) error {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-lock-token"); len(v) > 0 {
+			token = v[0]
+		}
+	}
+	if l, locked := collab.lockedByOther(id, token); locked {
+		return status.Errorf(codes.FailedPrecondition, "%v (held by %s until %s)",
+			errLockHeld, l.Holder, l.ExpiresAt.Format("15:04:05Z07:00"))
+	}
+	return nil
+}
+
+func contactInputFromProto(p *contactspb.ContactInput) ContactInput {
+	in := ContactInput{
+		FirstName: p.GetFirstName(),
+		LastName:  p.GetLastName(),
+		Company:   p.Company,
+		Email:     p.GetEmail(),
+		Phone:     p.Phone,
+	}
+	if p.GetCustomFields() != nil {
+		in.CustomFields = p.GetCustomFields().AsMap()
+	}
+	return in
+}
+
+func contactToProto(c Contact) (*contactspb.Contact, error) {
+	pc := &contactspb.Contact{
+		Id:        c.ID,
+		FirstName: c.FirstName,
+		LastName:  c.LastName,
+		Company:   c.Company,
+		Email:     c.Email,
+		Phone:     c.Phone,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+		UpdatedAt: timestamppb.New(c.UpdatedAt),
+	}
+	if len(c.CustomFields) > 0 {
+		s, err := structpb.NewStruct(c.CustomFields)
+		if err != nil {
+			return nil, status.Error(codes.Internal, fmt.Sprintf("custom fields: %v", err))
+		}
+		pc.CustomFields = s
+	}
+	return pc, nil
+}
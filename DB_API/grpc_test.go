@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"my-go-api/contactspb"
+)
+
+// grpcTestClient serves newGRPCServer over an in-memory listener.
+func grpcTestClient(t *testing.T) contactspb.ContactServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := newGRPCServer()
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return contactspb.NewContactServiceClient(conn)
+}
+
+// restCodes is the REST status to gRPC code mapping documented in
+// proto/contacts.proto.
+var restCodes = map[int]codes.Code{
+	http.StatusOK:                  codes.OK,
+	http.StatusCreated:             codes.OK,
+	http.StatusNoContent:           codes.OK,
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusLocked:              codes.FailedPrecondition,
+}
+
+// TestGRPCMatchesREST sends the same requests over gRPC and REST. Status codes
+// must match in every validation mode; messages must match with validation
+// off, as the OpenAPI layer words its errors after the spec.
+func TestGRPCMatchesREST(t *testing.T) {
+	for _, mode := range []string{validateOff, validateRequests} {
+		t.Run(mode, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.OpenAPI.Validation = mode })
+			testGRPCMatchesREST(t, mode == validateOff)
+		})
+	}
+}
+
+func testGRPCMatchesREST(t *testing.T, compareMessages bool) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		rpc := grpcTestClient(t)
+		ctx := context.Background()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		missing := id + 1000
+
+		for _, tt := range []struct {
+			name         string
+			method, path string
+			body         any
+			call         func() (*contactspb.Contact, error)
+		}{
+			{"get", http.MethodGet, fmt.Sprintf("/contacts/%d", id), nil, func() (*contactspb.Contact, error) {
+				return rpc.GetContact(ctx, &contactspb.GetContactRequest{Id: id})
+			}},
+			{"get zero id", http.MethodGet, "/contacts/0", nil, func() (*contactspb.Contact, error) {
+				return rpc.GetContact(ctx, &contactspb.GetContactRequest{Id: 0})
+			}},
+			{"get negative id", http.MethodGet, "/contacts/-1", nil, func() (*contactspb.Contact, error) {
+				return rpc.GetContact(ctx, &contactspb.GetContactRequest{Id: -1})
+			}},
+			{"get missing", http.MethodGet, fmt.Sprintf("/contacts/%d", missing), nil, func() (*contactspb.Contact, error) {
+				return rpc.GetContact(ctx, &contactspb.GetContactRequest{Id: missing})
+			}},
+			{"create invalid email", http.MethodPost, "/contacts",
+				map[string]any{"firstName": "Ada", "lastName": "King", "email": "ada"},
+				func() (*contactspb.Contact, error) {
+					return rpc.CreateContact(ctx, &contactspb.CreateContactRequest{Contact: &contactspb.ContactInput{FirstName: "Ada", LastName: "King", Email: "ada"}})
+				}},
+			{"create duplicate email", http.MethodPost, "/contacts",
+				map[string]any{"firstName": "Ada", "lastName": "King", "email": "ada@example.com"},
+				func() (*contactspb.Contact, error) {
+					return rpc.CreateContact(ctx, &contactspb.CreateContactRequest{Contact: &contactspb.ContactInput{FirstName: "Ada", LastName: "King", Email: "ada@example.com"}})
+				}},
+			{"patch", http.MethodPatch, fmt.Sprintf("/contacts/%d", id), map[string]any{"phone": "555-0100"}, func() (*contactspb.Contact, error) {
+				// Another value: an UPDATE changing nothing within the second reports no rows.
+				return rpc.PatchContact(ctx, &contactspb.PatchContactRequest{Id: id, Patch: &contactspb.ContactPatch{Phone: ptr("555-0101")}})
+			}},
+			{"patch missing", http.MethodPatch, fmt.Sprintf("/contacts/%d", missing), map[string]any{"phone": "555-0100"}, func() (*contactspb.Contact, error) {
+				return rpc.PatchContact(ctx, &contactspb.PatchContactRequest{Id: missing, Patch: &contactspb.ContactPatch{Phone: ptr("555-0100")}})
+			}},
+			{"patch zero id", http.MethodPatch, "/contacts/0", map[string]any{"phone": "555-0100"}, func() (*contactspb.Contact, error) {
+				return rpc.PatchContact(ctx, &contactspb.PatchContactRequest{Id: 0, Patch: &contactspb.ContactPatch{Phone: ptr("555-0100")}})
+			}},
+			{"delete zero id", http.MethodDelete, "/contacts/0", nil, func() (*contactspb.Contact, error) {
+				return rpc.DeleteContact(ctx, &contactspb.DeleteContactRequest{Id: 0})
+			}},
+			{"delete missing", http.MethodDelete, fmt.Sprintf("/contacts/%d", missing), nil, func() (*contactspb.Contact, error) {
+				return rpc.DeleteContact(ctx, &contactspb.DeleteContactRequest{Id: missing})
+			}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				var rest struct {
+					Error string `json:"error"`
+					Contact
+				}
+				restStatus := do(t, h, tt.method, tt.path, tt.body, &rest)
+				want, ok := restCodes[restStatus]
+				if !ok {
+					t.Fatalf("REST status %d has no gRPC code", restStatus)
+				}
+				got, err := tt.call()
+				st := status.Convert(err)
+				if st.Code() != want {
+					t.Fatalf("REST %d %q, gRPC %s %q; want %s", restStatus, rest.Error, st.Code(), st.Message(), want)
+				}
+				if err != nil {
+					if compareMessages && st.Message() != rest.Error {
+						t.Errorf("gRPC message %q, REST error %q", st.Message(), rest.Error)
+					}
+					return
+				}
+				if got.GetId() != rest.ID || got.GetEmail() != rest.Email || got.GetFirstName() != rest.FirstName || got.Phone != nil != (rest.Phone != nil) {
+					t.Errorf("gRPC contact %v, REST contact %+v", got, rest.Contact)
+				}
+			})
+		}
+	})
+}
+
+func TestGRPCRejectsMissingMessages(t *testing.T) {
+	rpc := grpcTestClient(t)
+	ctx := context.Background()
+	for name, call := range map[string]func() error{
+		"create without contact": func() error {
+			_, err := rpc.CreateContact(ctx, &contactspb.CreateContactRequest{})
+			return err
+		},
+		"update without contact": func() error {
+			_, err := rpc.UpdateContact(ctx, &contactspb.UpdateContactRequest{Id: 1})
+			return err
+		},
+		"patch without patch": func() error {
+			_, err := rpc.PatchContact(ctx, &contactspb.PatchContactRequest{Id: 1})
+			return err
+		},
+	} {
+		if code := status.Code(call()); code != codes.InvalidArgument {
+			t.Errorf("%s: %s, want InvalidArgument", name, code)
+		}
+	}
+}
+
+func TestGRPCRecoversPanics(t *testing.T) {
+	boom := func(context.Context, any) (any, error) { panic("boom") }
+	_, err := recoverUnaryPanics(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}, boom)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("unary panic: %v, want Internal", err)
+	}
+	stream := func(any, grpc.ServerStream) error { panic(errors.New("boom")) }
+	err = recoverStreamPanics(nil, fakeServerStream{}, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, stream)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("stream panic: %v, want Internal", err)
+	}
+}
+
+type fakeServerStream struct{ grpc.ServerStream }
+
+func (fakeServerStream) Context() context.Context { return context.Background() }
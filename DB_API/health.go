@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probes for the orchestrator:
+//
+//	GET /livez   the process is up and serving. Nothing else is checked, so
+//	             a MySQL outage does not get every replica restarted.
+//	GET /healthz runs the dependency checks and returns their breakdown;
+//	             503 if any fails.
+//	GET /readyz  the same checks, and 503 while draining for shutdown.
+//
+// Each check gets health.checkTimeout (HEALTH_CHECK_TIMEOUT) on its own and
+// they run concurrently, so a probe answers within that time.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn" // reported, but the instance stays ready
+	checkFail = "fail"
+
+	// poolBusyWarn is the share of the pool in use at which the pool check warns.
+	poolBusyWarn = 0.9
+	// poolWaitWindow is how far back the pool check counts queries that had
+	// to wait for a connection.
+	poolWaitWindow = 30 * time.Second
+)
+
+// migrated is set once migrate has run in this process.
+var migrated atomic.Bool
+
+type checkResult struct {
+	Status     string         `json:"status"`
+	DurationMS int64          `json:"durationMs"`
+	Error      string         `json:"error,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) checkResult
+}
+
+var healthChecks = []healthCheck{
+	{"database", checkDatabase},
+	{"migrations", checkMigrations},
+	{"pool", checkPool},
+	{"replicas", checkReplicas},
+}
+
+// runHealthChecks runs every check concurrently. The overall status is the
+// worst of theirs.
+func runHealthChecks(ctx context.Context) healthReport {
+	timeout := cfg().Health.CheckTimeout
+	results := make([]checkResult, len(healthChecks))
+	var wg sync.WaitGroup
+	for i, c := range healthChecks {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			res := c.run(ctx)
+			if ctx.Err() == context.DeadlineExceeded && res.Status == checkFail {
+				res.Error = fmt.Sprintf("timed out after %s", timeout)
+			}
+			res.DurationMS = time.Since(start).Milliseconds()
+			results[i] = res
+		})
+	}
+	wg.Wait()
+
+	report := healthReport{Status: checkOK, Checks: make(map[string]checkResult, len(results))}
+	for i, res := range results {
+		report.Checks[healthChecks[i].name] = res
+		switch {
+		case res.Status == checkFail:
+			report.Status = checkFail
+		case res.Status == checkWarn && report.Status == checkOK:
+			report.Status = checkWarn
+		}
+	}
+	return report
+}
+
+func checkDatabase(ctx context.Context) checkResult {
+	if err := db.PingContext(ctx); err != nil {
+		return checkResult{Status: checkFail, Error: err.Error()}
+	}
+	return checkResult{Status: checkOK}
+}
+
+var createTableRegex = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS\s+(\w+)`)
+
+// checkMigrations verifies that migrate ran and that every table it creates
+// is still there.
+func checkMigrations(ctx context.Context) checkResult {
+	if !migrated.Load() {
+		return checkResult{Status: checkFail, Error: "migrations have not run"}
+	}
+	want := make(map[string]bool)
+	for _, ddl := range dialect.migrations() {
+		if m := createTableRegex.FindStringSubmatch(ddl); m != nil {
+			want[m[1]] = true
+		}
+	}
+	tables := len(want)
+	rows, err := db.QueryContext(ctx,
+		`SELECT table_name FROM information_schema.tables WHERE table_schema = `+dialect.currentSchema())
+	if err != nil {
+		return checkResult{Status: checkFail, Error: err.Error()}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return checkResult{Status: checkFail, Error: err.Error()}
+		}
+		delete(want, name)
+	}
+	if err := rows.Err(); err != nil {
+		return checkResult{Status: checkFail, Error: err.Error()}
+	}
+	if len(want) > 0 {
+		missing := make([]string, 0, len(want))
+		for name := range want {
+			missing = append(missing, name)
+		}
+		return checkResult{Status: checkFail, Error: "missing tables: " + strings.Join(missing, ", ")}
+	}
+	return checkResult{Status: checkOK, Details: map[string]any{"tables": tables}}
+}
+
+// waitSamples remembers the pool's wait count as of each pool check, so that
+// a check can tell how many queries waited over the last window whichever
+// probe runs it and however often.
+type waitSamples struct {
+	mu      sync.Mutex
+	samples []waitSample // oldest first
+}
+
+type waitSample struct {
+	at    time.Time
+	count int64
+}
+
+var poolWaits waitSamples
+
+// since records count as of now and returns how much it grew over window:
+// from the newest sample at least window old, or the oldest one while there
+// is none that old yet.
+func (w *waitSamples) since(now time.Time, count int64, window time.Duration) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cutoff := now.Add(-window)
+	drop := 0
+	for drop+1 < len(w.samples) && !w.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	w.samples = append(w.samples[drop:], waitSample{at: now, count: count})
+	return count - w.samples[0].count
+}
+
+// checkPool reports pool usage. It warns when most connections are busy and
+// fails when all are and queries have had to wait for one in the last
+// poolWaitWindow, which takes a saturated instance out of rotation until it
+// recovers. /healthz and /readyz see the same window.
+func checkPool(context.Context) checkResult {
+	s := db.Stats()
+	waited := poolWaits.since(time.Now(), s.WaitCount, poolWaitWindow)
+	res := checkResult{Status: checkOK, Details: map[string]any{
+		"maxOpen":      s.MaxOpenConnections,
+		"open":         s.OpenConnections,
+		"inUse":        s.InUse,
+		"idle":         s.Idle,
+		"waitCount":    s.WaitCount,
+		"waitDuration": s.WaitDuration.String(),
+	}}
+	if s.MaxOpenConnections == 0 {
+		return res // unlimited
+	}
+	busy := float64(s.InUse) / float64(s.MaxOpenConnections)
+	switch {
+	case s.InUse >= s.MaxOpenConnections && waited > 0:
+		res.Status = checkFail
+		res.Error = fmt.Sprintf("all %d connections in use, %d queries waited in the last %s", s.MaxOpenConnections, waited, poolWaitWindow)
+	case busy >= poolBusyWarn:
+		res.Status = checkWarn
+		res.Error = fmt.Sprintf("%d of %d connections in use", s.InUse, s.MaxOpenConnections)
+	}
+	return res
+}
+
+// checkReplicas reports which read replicas are in rotation, as of their
+// last check. It warns while any is out, since the primary takes their
+// reads, and never fails.
+func checkReplicas(context.Context) checkResult {
+	if len(replicas.replicas) == 0 {
+		return checkResult{Status: checkOK}
+	}
+	res := checkResult{Status: checkOK, Details: make(map[string]any, len(replicas.replicas))}
+	var down []string
+	for _, r := range replicas.replicas {
+		if r.up.Load() {
+			res.Details[r.name] = "up"
+		} else {
+			res.Details[r.name] = "down"
+			down = append(down, r.name)
+		}
+	}
+	if len(down) > 0 {
+		res.Status = checkWarn
+		res.Error = "out of rotation: " + strings.Join(down, ", ")
+	}
+	return res
+}
+
+func writeHealth(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if report.Status == checkFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
+
+// livez handles GET /livez.
+func livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]string{"status": checkOK})
+}
+
+// healthz handles GET /healthz.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, runHealthChecks(r.Context()))
+}
+
+// readyz handles GET /readyz: the health checks while ready, 503 once draining.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+	writeHealth(w, runHealthChecks(r.Context()))
+}
+
+// isProbe reports whether path is one of the probe endpoints, which the
+// rate limiter leaves alone.
+func isProbe(path string) bool {
+	return path == "/livez" || path == "/healthz" || path == "/readyz"
+}
+
+// waitForDB pings the database until it answers, backing off from
+// dbRetryMin to dbRetryMax between attempts. It gives up after timeout (0:
+// never) or when ctx is cancelled, so MySQL may start after the API does.
+func waitForDB(ctx context.Context, timeout time.Duration) error {
+	const dbRetryMin, dbRetryMax = 500 * time.Millisecond, 15 * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	delay := dbRetryMin
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg().Health.CheckTimeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("db: connected", "attempts", attempt)
+			}
+			return nil
+		}
+		slog.Warn("db: ping failed; retrying", "attempt", attempt, "retry_in", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable: %w", err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, dbRetryMax)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolWaitWindow(t *testing.T) {
+	var w waitSamples
+	start := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		probe string
+		after time.Duration
+		count int64
+		want  int64
+	}{
+		{"healthz", 0, 5, 0},
+		{"readyz", 5 * time.Second, 8, 3},
+		// A second probe does not hide the waits the first one saw.
+		{"healthz", 10 * time.Second, 8, 3},
+		{"readyz", 15 * time.Second, 8, 3},
+		{"healthz", 30 * time.Second, 8, 3},
+		{"healthz", 35 * time.Second, 8, 0},
+		{"readyz", 40 * time.Second, 8, 0},
+		{"healthz", 45 * time.Second, 9, 1},
+	} {
+		if got := w.since(start.Add(tt.after), tt.count, 30*time.Second); got != tt.want {
+			t.Errorf("%s at +%s with %d waits: %d in the window, want %d", tt.probe, tt.after, tt.count, got, tt.want)
+		}
+	}
+	if len(w.samples) != 5 {
+		t.Errorf("kept %d samples, want the 5 from +15s on", len(w.samples))
+	}
+}
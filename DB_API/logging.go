@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Logs are written with log/slog, as JSON lines by default (log.format,
+// LOG_FORMAT) or as key=value text. log.level (LOG_LEVEL) is debug, info,
+// warn or error. Lines logged with a request's context carry its request_id
+// and trace_id. Every request is logged once it has been served, except that
+// log.sample (LOG_SAMPLE) keeps only a share of the successful requests to
+// busy routes, e.g. "/readyz=0.01; GET /contacts/events=0.1". With
+// log.redactPII (LOG_REDACT_PII) email addresses and phone numbers are
+// masked wherever they appear. Level, sampling and redaction apply on
+// SIGHUP.
+const headerTenant = "X-Tenant-ID"
+
+// newLogger builds the logger for the configured format. Level and
+// redaction are read from cfg() on each line, so reloads apply at once.
+func newLogger(w io.Writer, c LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}
+	var h slog.Handler = slog.NewJSONHandler(w, opts)
+	if c.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(logHandler{h})
+}
+
+// logHandler filters by the configured level and adds the request and trace
+// ids from the context.
+type logHandler struct{ slog.Handler }
+
+func (h logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= cfg().Log.level
+}
+
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := traceID(ctx); id != "" {
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
+
+var (
+	logEmailRegex = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)*\.[A-Za-z]{2,}\b`)
+	// A phone number is a run of at least seven digits with separators, as
+	// in "+1 (555) 010-0100" or "555-0100". Runs with no separator at all
+	// are left alone so that ids and counts survive, and so are dates,
+	// including a date followed by a time ("2026-10-19 10:30").
+	logPhoneRegex = regexp.MustCompile(`[+(]?\b\d[\d ()-]{5,}\d\b`)
+	logDateRegex  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\b`)
+)
+
+// redactAttr masks PII in string and error values while log.redactPII is
+// set. The message is an attribute too, so it is covered.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if !cfg().Log.RedactPII {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey, slog.LevelKey, "request_id", "trace_id", "stack":
+		return a
+	}
+	switch v := a.Value.Resolve(); {
+	case v.Kind() == slog.KindString:
+		a.Value = slog.StringValue(redactPII(v.String()))
+	case v.Kind() == slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			a.Value = slog.StringValue(redactPII(err.Error()))
+		}
+	}
+	return a
+}
+
+func redactPII(s string) string {
+	s = logEmailRegex.ReplaceAllString(s, "[email]")
+	return logPhoneRegex.ReplaceAllStringFunc(s, func(m string) string {
+		digits := 0
+		for _, c := range m {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits < 7 || logDateRegex.MatchString(m) || !strings.ContainsAny(m, " ()-+") {
+			return m
+		}
+		return "[phone]"
+	})
+}
+
+// logRequests logs each request after it has been served, at error level
+// for 5xx responses.
+func logRequests(mux *chi.Mux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := routePattern(mux, r)
+			status := servedStatus(ww, r)
+			if status < 400 && !sampled(r.Method, route) {
+				return
+			}
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote", r.RemoteAddr),
+			}
+			if user := strings.TrimSpace(r.Header.Get(headerUser)); user != "" {
+				attrs = append(attrs, slog.String("user", user))
+			}
+			if tenant := strings.TrimSpace(r.Header.Get(headerTenant)); tenant != "" {
+				attrs = append(attrs, slog.String("tenant", tenant))
+			}
+			slog.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// sampled decides whether a successful request to route is logged.
+func sampled(method, route string) bool {
+	sample := cfg().Log.sample
+	if len(sample) == 0 {
+		return true
+	}
+	route = normalizeRateRoute(route)
+	ratio, ok := sample[method+" "+route]
+	if !ok {
+		ratio, ok = sample[route]
+	}
+	return !ok || rand.Float64() < ratio
+}
+
+// recoverPanics turns a panic in a handler into a 500 and logs it with its
+// stack, in place of chi's Recoverer, which prints to stderr.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			slog.ErrorContext(r.Context(), "panic", "panic", rec, "stack", string(debug.Stack()))
+			if !isUpgrade(r) {
+				writeError(w, http.StatusInternalServerError, errors.New("internal server error"))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fatal logs a startup failure and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
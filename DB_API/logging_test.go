@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactPII(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"contact ada@example.com created", "contact [email] created"},
+		{"to a.b+tag@mail.example.co.uk, cc x_y@host-name.io", "to [email], cc [email]"},
+		{"phone +1 (555) 010-0100 saved", "phone [phone] saved"},
+		{"call 555-0100 now", "call [phone] now"},
+		{"+44 20 7946 0958", "[phone]"},
+		{"(555) 010 0100", "[phone]"},
+		{"contact 12345678 not found", "contact 12345678 not found"},
+		{"due 2026-10-19", "due 2026-10-19"},
+		{"due 2026-10-19 10:30", "due 2026-10-19 10:30"},
+		{"at 2026-10-19T10:30:00Z", "at 2026-10-19T10:30:00Z"},
+		{"page 2 of 10, 50 per page", "page 2 of 10, 50 per page"},
+		{"dial tcp 127.0.0.1:3306: connection refused", "dial tcp 127.0.0.1:3306: connection refused"},
+		{"ext 555-01", "ext 555-01"},
+		{"no pii here", "no pii here"},
+		{"", ""},
+	} {
+		if got := redactPII(tt.in); got != tt.want {
+			t.Errorf("redactPII(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerRedactsPII(t *testing.T) {
+	for _, redact := range []bool{true, false} {
+		withConfig(t, func(c *Config) { c.Log.RedactPII = redact })
+		var buf bytes.Buffer
+		newLogger(&buf, LogConfig{Format: "json"}).Error("notify ada@example.com",
+			"err", errors.New("rcpt ada@example.com: rejected"),
+			"phone", "555-0100",
+			"trace_id", "2026-10-19 555-0100")
+		out := buf.String()
+		if leaked := strings.Contains(out, "ada@example.com") || strings.Contains(out, `"phone":"555-0100"`); leaked == redact {
+			t.Errorf("redactPII %v: %s", redact, out)
+		}
+		if !strings.Contains(out, `"trace_id":"2026-10-19 555-0100"`) {
+			t.Errorf("redactPII %v: trace_id changed: %s", redact, out)
+		}
+	}
+}
@@ -1,21 +1,31 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
 )
 
 type Contact struct {
@@ -27,6 +37,8 @@ type Contact struct {
 	Phone     *string   `json:"phone,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	CustomFields map[string]any `json:"customFields,omitempty"`
 }
 
 type ContactInput struct {
@@ -35,6 +47,8 @@ type ContactInput struct {
 	Company   *string `json:"company"`
 	Email     string  `json:"email"`
 	Phone     *string `json:"phone"`
+
+	CustomFields map[string]any `json:"customFields"`
 }
 
 type PartialContact struct {
@@ -43,10 +57,14 @@ type PartialContact struct {
 	Company   *string `json:"company"`
 	Email     *string `json:"email"`
 	Phone     *string `json:"phone"`
+
+	// CustomFields is merged into the stored values; a null value clears that field.
+	CustomFields map[string]any `json:"customFields"`
 }
 
 type errorResponse struct {
-	Error string `json:"error"`
+	Error   string `json:"error"`
+	TraceID string `json:"traceId,omitempty"`
 }
 
 var (
@@ -55,57 +73,191 @@ var (
 )
 
 func main() {
-	// Environment-driven configuration
-	// Example: MYSQL_DSN="user:pass@tcp(127.0.0.1:3306)/contactsdb?parseTime=true&charset=utf8mb4"
-	dsn := os.Getenv("MYSQL_DSN")
-	if dsn == "" {
-		// Safe default for local dev (adjust user/pass/db as needed)
-		dsn = "root:RootRoot@tcp(127.0.0.1:3306)/contactsdb?parseTime=true&charset=utf8mb4"
+	// Configuration from defaults, CONFIG_FILE, env and flags; see config.go.
+	// Example: DB_DSN="user:pass@tcp(127.0.0.1:3306)/contactsdb?parseTime=true&charset=utf8mb4"
+	loader, err := newConfigLoader(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	conf, warnings, err := loader.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if loader.print {
+		fmt.Print(conf.Redacted())
+		return
 	}
+	currentConfig.Store(conf)
+	// Logging, e.g. LOG_LEVEL=debug LOG_FORMAT=text LOG_REDACT_PII=true
+	slog.SetDefault(newLogger(os.Stdout, conf.Log))
+	for _, w := range warnings {
+		slog.Warn("config: " + w)
+	}
+	slog.Info("effective configuration", "config", conf.Redacted())
 
-	var err error
-	db, err = sql.Open("mysql", dsn)
+	// Tracing, e.g. OTEL_TRACES_EXPORTER=otlp OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4318
+	shutdownTracing, err := initTracing(context.Background(), conf.Tracing)
 	if err != nil {
-		log.Fatalf("open db: %v", err)
+		fatal("tracing", err)
+	}
+
+	dialect = dialectFor(conf.DB.Driver)
+	db, err = openDB(conf.DB)
+	if err != nil {
+		fatal("open db", err)
+	}
+	registerDBMetrics(db, conf.DB.name())
+	// Read replicas, e.g. DB_REPLICA_DSNS="user:pass@tcp(replica1:3306)/contactsdb?parseTime=true"
+	if err := replicas.open(conf.DB); err != nil {
+		fatal("open db", err)
 	}
-	defer db.Close()
+	setPoolLimits(conf.DB)
 
-	// Connection pool settings (tune as needed)
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxLifetime(30 * time.Minute)
+	// SIGINT/SIGTERM begins shutdown. Background workers have a context of
+	// their own, cancelled only once the servers have drained, and the pools
+	// stay open until every worker has returned.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
 
-	// Ping to ensure connectivity
-	if err := db.Ping(); err != nil {
-		log.Fatalf("db ping: %v", err)
+	// Wait for MySQL, e.g. DB_STARTUP_TIMEOUT=5m ("0" waits until stopped)
+	if err := waitForDB(ctx, conf.DB.StartupTimeout); err != nil {
+		fatal("db", err)
 	}
 
-	if err := migrate(); err != nil {
-		log.Fatalf("db migrate: %v", err)
+	if err := migrate(ctx); err != nil {
+		fatal("db migrate", err)
 	}
+	if err := prepareStatements(ctx, db, replicas.pools(), conf.DB.StmtCacheSize); err != nil {
+		fatal("db prepare", err)
+	}
+	workers.Go(func() { replicas.run(workerCtx, conf.DB.ReplicaCheckInterval) })
 
-	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	r.Route("/contacts", func(r chi.Router) {
-		r.Get("/", listContacts)
-		r.Post("/", createContact)
-		r.Get("/{id}", getContact)
-		r.Put("/{id}", updateContact)
-		r.Patch("/{id}", patchContact)
-		r.Delete("/{id}", deleteContact)
+	// Background duplicate detection ("0" disables)
+	workers.Go(func() { runDuplicateScanner(workerCtx, conf.Duplicates.ScanInterval) })
+
+	// Reminder scheduler
+	notifier, err := newNotifier(conf.Reminders)
+	if err != nil {
+		fatal("reminders", err)
+	}
+	workers.Go(func() { runReminderScheduler(workerCtx, conf.Reminders.PollInterval, notifier) })
+
+	// Outbound webhook delivery with retries
+	workers.Go(func() { webhooks.run(workerCtx) })
+
+	// Relay contact events from the outbox, e.g. OUTBOX_SINKS=webhook,stdout,nats
+	sinks, err := newSinks(conf.Outbox)
+	if err != nil {
+		fatal("outbox", err)
+	}
+	relay.useSinks(sinks)
+	workers.Go(func() { relay.run(workerCtx) })
+
+	// Live event stream for GET /contacts/events
+	workers.Go(func() { eventHub.runTail(workerCtx) })
+
+	// Live collaboration rooms for GET /contacts/{id}/live
+	workers.Go(func() { collab.run(workerCtx) })
+
+	// gRPC ContactService next to the REST API; GRPC_ADDR=off disables it
+	var grpcServer *grpc.Server
+	if grpcAddr := conf.GRPC.Addr; grpcAddr != "off" {
+		grpcServer = newGRPCServer()
+		go func() {
+			if err := serveGRPC(grpcServer, grpcAddr); err != nil {
+				fatal("grpc", err)
+			}
+		}()
+	}
+
+	if err := initGraphQL(conf.GraphQL.MaxDepth); err != nil {
+		fatal("graphql", err)
+	}
+	if err := initOpenAPI(); err != nil {
+		fatal("openapi", err)
+	}
+	if err := initOpenAPIValidation(); err != nil {
+		fatal("openapi", err)
+	}
+
+	// Per-client rate limits, e.g. RATE_LIMIT=600/m RATE_LIMIT_ROUTES="POST /contacts=30/m"
+	limiter, err := newRateLimiter(conf.RateLimit)
+	if err != nil {
+		fatal("ratelimit", err)
+	}
+
+	// SIGHUP reloads the settings that can change while running.
+	workers.Go(func() {
+		loader.watchReloads(workerCtx.Done(), func(old, c *Config) {
+			setPoolLimits(c.DB)
+			limiter.rules.Store(c.RateLimit.rules)
+		})
 	})
 
-	addr := ":8080"
-	log.Printf("Contacts API listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, r))
+	r := newRouter(limiter)
+
+	srv := newHTTPServer(r, conf.HTTP)
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		fatal("listen", err)
+	}
+	ready.Store(true)
+	slog.Info("Contacts API listening", "addr", srv.Addr)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+
+	select {
+	case err := <-serveErr:
+		fatal("http", err)
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first and give load balancers http.drainDelay to
+	// notice, then drain in-flight HTTP and gRPC requests together within
+	// http.shutdownTimeout.
+	ready.Store(false)
+	drainDelay, timeout := cfg().HTTP.DrainDelay, cfg().HTTP.ShutdownTimeout
+	slog.Info("shutting down: draining connections", "timeout", drainDelay+timeout)
+	time.Sleep(drainDelay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	beginShutdown()
+	var servers sync.WaitGroup
+	if grpcServer != nil {
+		servers.Go(func() { stopGRPC(drainCtx, grpcServer) })
+	}
+	if err := srv.Shutdown(drainCtx); err != nil {
+		slog.Warn("http shutdown: closing remaining connections", "err", err)
+		srv.Close()
+	}
+	servers.Wait()
+
+	// Nothing new reaches the workers now; let them finish what they are
+	// doing before the pools close under them.
+	stopWorkers()
+	if !waitTimeout(&workers, timeout) {
+		slog.Warn("shutdown: background workers still running", "timeout", timeout)
+	}
+	if err := shutdownTracing(drainCtx); err != nil {
+		slog.Error("tracing shutdown", "err", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("close db", "err", err)
+	}
+	replicas.close()
+	slog.Info("shutdown complete")
 }
 
-func migrate() error {
-	ddl := `
+const contactsDDL = `
 CREATE TABLE IF NOT EXISTS contacts (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
   first_name VARCHAR(100) NOT NULL,
@@ -118,55 +270,85 @@ CREATE TABLE IF NOT EXISTS contacts (
   INDEX idx_email (email)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 `
-	_, err := db.Exec(ddl)
-	return err
+
+// contactRoutes is mounted once per API version; handlers render contacts
+// with the version negotiated for the request.
+func contactRoutes(r chi.Router) {
+	r.Get("/", listContacts)
+	r.Post("/", createContact)
+	r.With(endOnShutdown).Get("/export.csv", exportContactsCSV)
+	r.With(endOnShutdown).Get("/events", streamContactEvents)
+	r.Get("/duplicates", listDuplicates)
+	r.Post("/duplicates/scan", startDuplicateScan)
+	r.Get("/duplicates/scan", duplicateScanStatus)
+	r.Post("/duplicates/{pairId}/dismiss", dismissDuplicate)
+	r.Get("/{id}", getContact)
+	r.Put("/{id}", updateContact)
+	r.Patch("/{id}", patchContact)
+	r.Delete("/{id}", deleteContact)
+	r.With(endOnShutdown).Get("/{id}/live", contactLive)
+	r.Post("/{id}/merge", mergeContact)
+	r.Get("/{id}/merges", listContactMerges)
+	r.Get("/{id}/relationships", listRelationships)
+	r.Post("/{id}/relationships", createRelationship)
+	r.Delete("/{id}/relationships/{relId}", deleteRelationship)
+	r.Get("/{id}/network", getContactNetwork)
+	r.Post("/{id}/notes", createNote)
+	r.Get("/{id}/notes/{noteId}", getNote)
+	r.Patch("/{id}/notes/{noteId}", patchNote)
+	r.Delete("/{id}/notes/{noteId}", deleteNote)
+	r.Get("/{id}/timeline", getTimeline)
+	r.Get("/{id}/reminders", listContactReminders)
+	r.Post("/{id}/reminders", createReminder)
+}
+
+// migrations create the schema; each is idempotent and they run in order.
+var mysqlMigrations = []string{
+	contactsDDL,
+	customFieldsDDL,
+	contactFieldValuesDDL,
+	duplicateCandidatesDDL,
+	contactMergesDDL,
+	contactRelationshipsDDL,
+	contactNotesDDL,
+	contactRemindersDDL,
+	webhookSubscriptionsDDL,
+	webhookDeliveriesDDL,
+	eventOutboxDDL,
+	outboxOffsetsDDL,
+}
+
+// migrate applies the DDL. It is not subject to db.queryTimeout, since
+// altering a large table can take a while.
+func migrate(ctx context.Context) error {
+	if err := dialect.migrate(ctx, db); err != nil {
+		return err
+	}
+	migrated.Store(true)
+	return nil
 }
 
 func listContacts(w http.ResponseWriter, r *http.Request) {
 	page := parseIntDefault(r.URL.Query().Get("page"), 1)
-	pageSize := parseIntDefault(r.URL.Query().Get("pageSize"), 50)
+	pageSize := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("pageSize"), 0))
 	if page < 1 {
 		page = 1
 	}
-	if pageSize < 1 || pageSize > 200 {
-		pageSize = 50
-	}
 	offset := (page - 1) * pageSize
 
-	rows, err := db.Query(`
-SELECT id, first_name, last_name, company, email, phone, created_at, updated_at
-FROM contacts
-ORDER BY id
-LIMIT ? OFFSET ?`, pageSize, offset)
+	var items []Contact
+	err := readReplica(r.Context(), func(q queryer) (err error) {
+		items, err = queryContacts(r.Context(), q, contactQuery{Filters: r.URL.Query()}, pageSize, offset)
+		return err
+	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		writeAPIError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var items []Contact
-	for rows.Next() {
-		var c Contact
-		var company, phone sql.NullString
-		var created, updated time.Time
-		if err := rows.Scan(&c.ID, &c.FirstName, &c.LastName, &company, &c.Email, &phone, &created, &updated); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-		if company.Valid {
-			c.Company = &company.String
-		}
-		if phone.Valid {
-			c.Phone = &phone.String
-		}
-		c.CreatedAt = created
-		c.UpdatedAt = updated
-		items = append(items, c)
-	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"page":     page,
 		"pageSize": pageSize,
-		"items":    items,
+		"items":    renderContacts(r, items),
 	})
 }
 
@@ -177,30 +359,40 @@ func getContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var c Contact
-	var company, phone sql.NullString
-	var created, updated time.Time
-	err = db.QueryRow(`
-SELECT id, first_name, last_name, company, email, phone, created_at, updated_at
-FROM contacts WHERE id = ?`, id).
-		Scan(&c.ID, &c.FirstName, &c.LastName, &company, &c.Email, &phone, &created, &updated)
+	err = readReplica(r.Context(), func(q queryer) (err error) {
+		c, err = loadContact(r.Context(), q, id)
+		return err
+	})
 	if errors.Is(err, sql.ErrNoRows) {
 		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		writeAPIError(w, err)
 		return
 	}
-	if company.Valid {
-		c.Company = &company.String
-	}
-	if phone.Valid {
-		c.Phone = &phone.String
+	writeJSON(w, http.StatusOK, renderContact(r, c))
+}
+
+// loadContact reads one contact including its custom field values. Pass a
+// *sql.Tx to see the transaction's own uncommitted writes.
+func loadContact(ctx context.Context, q queryer, id int64) (Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	c, err := scanContact(stmts.queryRow(ctx, q, sqlContactByID, id))
+	if err != nil {
+		return c, err
 	}
-	c.CreatedAt = created
-	c.UpdatedAt = updated
 
-	writeJSON(w, http.StatusOK, c)
+	defs, err := loadCustomFields(ctx, q)
+	if err != nil {
+		return c, err
+	}
+	items := []Contact{c}
+	if err := attachCustomValues(ctx, q, defs, items); err != nil {
+		return c, err
+	}
+	return items[0], nil
 }
 
 func createContact(w http.ResponseWriter, r *http.Request) {
@@ -209,39 +401,12 @@ func createContact(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := validateInput(in); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, err)
-		return
-	}
-
-	// created_at and updated_at are handled by MySQL defaults, but we can set explicitly if desired
-	now := time.Now().UTC().Truncate(time.Second)
-
-	res, err := db.Exec(`
-INSERT INTO contacts (first_name, last_name, company, email, phone, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		in.FirstName, in.LastName, nullable(in.Company), in.Email, nullable(in.Phone), now, now)
+	contact, err := insertContact(r.Context(), in)
 	if err != nil {
-		if isUniqueEmailErr(err) {
-			writeError(w, http.StatusConflict, fmt.Errorf("email already exists"))
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err)
+		writeAPIError(w, err)
 		return
 	}
-	id, _ := res.LastInsertId()
-
-	contact := Contact{
-		ID:        id,
-		FirstName: in.FirstName,
-		LastName:  in.LastName,
-		Company:   in.Company,
-		Email:     in.Email,
-		Phone:     in.Phone,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-	writeJSON(w, http.StatusCreated, contact)
+	writeJSON(w, http.StatusCreated, renderContact(r, contact))
 }
 
 func updateContact(w http.ResponseWriter, r *http.Request) {
@@ -250,37 +415,21 @@ func updateContact(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if rejectIfLocked(w, r, id) {
+		return
+	}
 
 	var in ContactInput
 	if err := decodeJSON(r, &in); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := validateInput(in); err != nil {
-		writeError(w, http.StatusUnprocessableEntity, err)
-		return
-	}
-	now := time.Now().UTC().Truncate(time.Second)
-
-	res, err := db.Exec(`
-UPDATE contacts
-SET first_name = ?, last_name = ?, company = ?, email = ?, phone = ?, updated_at = ?
-WHERE id = ?`,
-		in.FirstName, in.LastName, nullable(in.Company), in.Email, nullable(in.Phone), now, id)
+	c, err := replaceContact(r.Context(), id, in)
 	if err != nil {
-		if isUniqueEmailErr(err) {
-			writeError(w, http.StatusConflict, fmt.Errorf("email already exists"))
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+		writeAPIError(w, err)
 		return
 	}
-	getContact(w, r) // return the updated resource
+	writeJSON(w, http.StatusOK, renderContact(r, c)) // return the updated resource
 }
 
 func patchContact(w http.ResponseWriter, r *http.Request) {
@@ -289,72 +438,20 @@ func patchContact(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if rejectIfLocked(w, r, id) {
+		return
+	}
 	var in PartialContact
 	if err := decodeJSON(r, &in); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-
-	fields := make([]string, 0, 6)
-	args := make([]any, 0, 7)
-	if in.FirstName != nil {
-		if strings.TrimSpace(*in.FirstName) == "" {
-			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("firstName is required"))
-			return
-		}
-		fields = append(fields, "first_name = ?")
-		args = append(args, *in.FirstName)
-	}
-	if in.LastName != nil {
-		if strings.TrimSpace(*in.LastName) == "" {
-			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("lastName is required"))
-			return
-		}
-		fields = append(fields, "last_name = ?")
-		args = append(args, *in.LastName)
-	}
-	if in.Company != nil {
-		fields = append(fields, "company = ?")
-		args = append(args, nullable(in.Company))
-	}
-	if in.Email != nil {
-		if !emailRegex.MatchString(*in.Email) {
-			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("invalid email"))
-			return
-		}
-		fields = append(fields, "email = ?")
-		args = append(args, *in.Email)
-	}
-	if in.Phone != nil {
-		fields = append(fields, "phone = ?")
-		args = append(args, nullable(in.Phone))
-	}
-	fields = append(fields, "updated_at = ?")
-	now := time.Now().UTC().Truncate(time.Second)
-	args = append(args, now)
-	args = append(args, id)
-
-	if len(args) == 2 { // only updated_at + id
-		writeError(w, http.StatusBadRequest, fmt.Errorf("no updatable fields provided"))
-		return
-	}
-
-	q := fmt.Sprintf("UPDATE contacts SET %s WHERE id = ?", strings.Join(fields, ", "))
-	res, err := db.Exec(q, args...)
+	c, err := applyContactPatch(r.Context(), id, in)
 	if err != nil {
-		if isUniqueEmailErr(err) {
-			writeError(w, http.StatusConflict, fmt.Errorf("email already exists"))
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err)
+		writeAPIError(w, err)
 		return
 	}
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
-		return
-	}
-	getContact(w, r)
+	writeJSON(w, http.StatusOK, renderContact(r, c))
 }
 
 func deleteContact(w http.ResponseWriter, r *http.Request) {
@@ -363,14 +460,11 @@ func deleteContact(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	res, err := db.Exec(`DELETE FROM contacts WHERE id = ?`, id)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	if rejectIfLocked(w, r, id) {
 		return
 	}
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	if _, err := removeContact(r.Context(), id); err != nil {
+		writeAPIError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -378,11 +472,19 @@ func deleteContact(w http.ResponseWriter, r *http.Request) {
 
 // Helpers
 
+// decodeJSON reads the request body into v, in its own span since reading a
+// large or slow body can dominate a request.
 func decodeJSON(r *http.Request, v any) error {
+	_, span := tracer.Start(r.Context(), "decode JSON")
+	defer span.End()
 	defer r.Body.Close()
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
-	return dec.Decode(v)
+	if err := dec.Decode(v); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -391,8 +493,88 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// writeError sends err as JSON, with the trace id traceRequests put in the
+// response headers so that a failure can be found in the tracing backend.
 func writeError(w http.ResponseWriter, status int, err error) {
-	writeJSON(w, status, errorResponse{Error: err.Error()})
+	writeJSON(w, status, errorResponse{Error: err.Error(), TraceID: w.Header().Get(traceIDHeader)})
+}
+
+// apiError is an error from the shared contact operations together with the
+// HTTP status it maps to. Errors without one are internal (500).
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+func (e *apiError) Unwrap() error { return e.err }
+
+func statusErr(status int, err error) error {
+	return &apiError{status: status, err: err}
+}
+
+// statusClientClosedRequest is logged for requests whose client went away
+// before the response; nginx uses the same code.
+const statusClientClosedRequest = 499
+
+var (
+	errTimeout     = errors.New("timed out waiting for the database")
+	errUnavailable = errors.New("database unavailable")
+)
+
+// errStatus returns the HTTP status for err. A deadline hit while waiting
+// for MySQL is 504 and a connection that could not be made or was lost is
+// 503, so clients and load balancers can tell them from a bug.
+func errStatus(err error) int {
+	var ae *apiError
+	switch {
+	case errors.As(err, &ae):
+		return ae.status
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case isUnavailableErr(err):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// isUnavailableErr reports whether err means the database could not be
+// reached, as opposed to rejecting the statement. PostgreSQL reports a
+// server starting up or shutting down as SQLSTATE class 57P.
+func isUnavailableErr(err error) bool {
+	var netErr net.Error
+	var pgErr *pgconn.PgError
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) ||
+		errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "57P")
+}
+
+// writeAPIError sends err with the status errStatus picks for it. Timeouts
+// and outages get a fixed message rather than the driver's, and 503 asks
+// the client to retry.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := errStatus(err)
+	switch status {
+	case http.StatusGatewayTimeout:
+		err = errTimeout
+	case http.StatusServiceUnavailable:
+		if errors.Is(err, errUnavailable) || isUnavailableErr(err) {
+			w.Header().Set("Retry-After", "5")
+			err = errUnavailable
+		}
+	}
+	writeError(w, status, err)
+}
+
+// dbCtx derives the context for one storage call, bounded by
+// db.queryTimeout as well as by ctx. Call cancel once the rows are closed.
+func dbCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := cfg().DB.QueryTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
 }
 
 func parseIDParam(s string) (int64, error) {
@@ -403,6 +585,16 @@ func parseIDParam(s string) (int64, error) {
 	return id, nil
 }
 
+// setPoolLimits applies the connection pool settings, to the primary's pool
+// and each replica's; they can be reloaded.
+func setPoolLimits(c DBConfig) {
+	for _, pool := range append([]*sql.DB{db}, replicas.pools()...) {
+		pool.SetMaxOpenConns(c.MaxOpenConns)
+		pool.SetMaxIdleConns(c.MaxIdleConns)
+		pool.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
+
 func parseIntDefault(s string, def int) int {
 	if s == "" {
 		return def
@@ -414,6 +606,16 @@ func parseIntDefault(s string, def int) int {
 	return n
 }
 
+// pageSizeOrDefault keeps a requested page size within pagination.maxPageSize,
+// falling back to pagination.defaultPageSize.
+func pageSizeOrDefault(n int) int {
+	p := cfg().Pagination
+	if n < 1 || n > p.MaxPageSize {
+		return p.DefaultPageSize
+	}
+	return n
+}
+
 func nullable(p *string) any {
 	if p == nil {
 		return nil
@@ -434,8 +636,62 @@ func validateInput(in ContactInput) error {
 	return nil
 }
 
-func isUniqueEmailErr(err error) bool {
-	s := strings.ToLower(err.Error())
-	// MySQL constraint messages can vary by server version/SQL mode.
-	return strings.Contains(s, "duplicate") && strings.Contains(s, "email")
+// newRouter builds the HTTP API, with limiter applying the rate limits.
+func newRouter(limiter *rateLimiter) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(traceRequests(r))
+	r.Use(logRequests(r))
+	r.Use(instrument(r))
+	r.Use(recoverPanics)
+	r.Use(timeoutRequests)
+	r.Use(stampWrites)
+	r.Use(limiter.middleware(r))
+	r.Use(validateOpenAPI)
+
+	// /contacts negotiates its version from Accept; /v1 and /v2 pin it.
+	r.With(negotiateAPIVersion).Route("/contacts", contactRoutes)
+	r.With(negotiateAPIVersion).Route("/v1/contacts", contactRoutes)
+	r.With(negotiateAPIVersion).Route("/v2/contacts", contactRoutes)
+
+	r.Get("/notes/search", searchNotes)
+
+	r.Get("/livez", livez)
+	r.Get("/healthz", healthz)
+	r.Get("/readyz", readyz)
+	r.Method(http.MethodGet, metricsPath, serveMetrics())
+
+	r.Get("/openapi.json", serveOpenAPI)
+	r.Get("/docs", serveDocs)
+
+	r.Post("/graphql", serveGraphQL)
+	r.With(endOnShutdown).Get("/graphql", serveGraphQL)
+
+	r.Route("/reminders", func(r chi.Router) {
+		r.Get("/due", listDueReminders)
+		r.Get("/{reminderId}", getReminder)
+		r.Patch("/{reminderId}", patchReminder)
+		r.Post("/{reminderId}/complete", completeReminder)
+		r.Delete("/{reminderId}", deleteReminder)
+	})
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Get("/", listWebhooks)
+		r.Post("/", createWebhook)
+		r.Get("/dead-letters", listDeadLetters)
+		r.Post("/deliveries/{deliveryId}/retry", retryDelivery)
+		r.Get("/{webhookId}", getWebhook)
+		r.Delete("/{webhookId}", deleteWebhook)
+		r.Get("/{webhookId}/deliveries", listWebhookDeliveries)
+	})
+
+	r.Route("/custom-fields", func(r chi.Router) {
+		r.Get("/", listCustomFields)
+		r.Post("/", createCustomField)
+		r.Get("/{name}", getCustomField)
+		r.Delete("/{name}", deleteCustomField)
+	})
+
+	return r
 }
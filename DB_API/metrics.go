@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// GET /metrics serves Prometheus metrics: HTTP traffic per chi route
+// pattern, the connection pool (go_sql_* from sql.DBStats), Go runtime and
+// process stats, and counters for what the API does.
+const metricsPath = "/metrics"
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route pattern and status code. Streams and WebSockets count for as long as they stay open.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests being served, including open streams.",
+	})
+
+	contactsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "contacts_created_total",
+		Help: "Contacts created.",
+	})
+	contactsUpdated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "contacts_updated_total",
+		Help: "Contact updates (PUT and PATCH).",
+	})
+	contactsDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "contacts_deleted_total",
+		Help: "Contacts deleted, not counting merge sources.",
+	})
+	contactsMerged = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "contacts_merged_total",
+		Help: "Contact merges.",
+	})
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_delivery_attempts_total",
+		Help: "Webhook delivery attempts by result: succeeded, retrying or dead.",
+	}, []string{"result"})
+	remindersNotified = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reminders_notified_total",
+		Help: "Due reminder notifications by result: sent or failed.",
+	}, []string{"result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight,
+		contactsCreated, contactsUpdated, contactsDeleted, contactsMerged,
+		webhookDeliveries, remindersNotified,
+	)
+}
+
+// registerDBMetrics exports the pool's sql.DBStats, labelled with dbName.
+func registerDBMetrics(db *sql.DB, dbName string) {
+	metricsRegistry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+func serveMetrics() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{Registry: metricsRegistry})
+}
+
+// instrument records every request under its route pattern, so that
+// /contacts/1 and /contacts/2 are one series.
+func instrument(mux *chi.Mux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			httpInFlight.Inc()
+			defer httpInFlight.Dec()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := routePattern(mux, r)
+			status := servedStatus(ww, r)
+			labels := prometheus.Labels{"method": methodLabel(r.Method), "route": route, "status": strconv.Itoa(status)}
+			httpRequests.With(labels).Inc()
+			httpDuration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// servedStatus is the status ww sent, filling in what net/http implies
+// when a handler wrote nothing or hijacked the connection.
+func servedStatus(ww middleware.WrapResponseWriter, r *http.Request) int {
+	switch status := ww.Status(); {
+	case status == 0 && isUpgrade(r):
+		return http.StatusSwitchingProtocols // hijacked
+	case status == 0:
+		return http.StatusOK
+	default:
+		return status
+	}
+}
+
+// routePattern is the chi pattern that served r, once it has been served.
+// Requests answered before routing (rate limited, failed validation) are
+// looked up in mux; anything that matches no route is "unmatched", which
+// keeps scanners from creating a series or span name per path.
+func routePattern(mux *chi.Mux, r *http.Request) string {
+	route := ""
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		route = rctx.RoutePattern()
+	}
+	if route == "" {
+		route = mux.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	return route
+}
+
+// methodLabel is r.Method for the standard methods and "OTHER" for anything
+// else, which net/http accepts as any token and a client could vary freely.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestMetricsMethodLabel sends made-up methods, which net/http accepts, and
+// checks that they share one series.
+func TestMetricsMethodLabel(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(instrument(mux))
+	mux.Get("/ping", func(http.ResponseWriter, *http.Request) {})
+	for _, method := range []string{http.MethodGet, "FOO", "BAR1", "get"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/ping", nil))
+	}
+
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := make(map[string]bool)
+	for _, mf := range families {
+		if mf.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "method" {
+					methods[l.GetValue()] = true
+				}
+			}
+		}
+	}
+	for _, method := range []string{"FOO", "BAR1", "get"} {
+		if methods[method] {
+			t.Errorf("method label %q recorded; want it counted as OTHER", method)
+		}
+	}
+	if !methods["OTHER"] || !methods[http.MethodGet] {
+		t.Errorf("method labels %v, want GET and OTHER", methods)
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Timeline entry kinds. "note" is a free-form note; the others record an interaction.
+const (
+	noteKindNote    = "note"
+	noteKindCall    = "call"
+	noteKindEmail   = "email"
+	noteKindMeeting = "meeting"
+)
+
+var noteKinds = []string{noteKindNote, noteKindCall, noteKindEmail, noteKindMeeting}
+
+const maxNoteBodyLen = 65535
+
+// Note is a timeline entry attached to a contact. Body is markdown and is
+// stored and returned verbatim; rendering is left to the client.
+type Note struct {
+	ID         int64     `json:"id"`
+	ContactID  int64     `json:"contactId"`
+	Kind       string    `json:"kind"`
+	Author     string    `json:"author"`
+	Subject    *string   `json:"subject,omitempty"`
+	Body       string    `json:"body"`
+	OccurredAt time.Time `json:"occurredAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type NoteInput struct {
+	Kind       string     `json:"kind"`
+	Author     string     `json:"author"`
+	Subject    *string    `json:"subject"`
+	Body       string     `json:"body"`
+	OccurredAt *time.Time `json:"occurredAt"`
+}
+
+type PartialNote struct {
+	Kind       *string    `json:"kind"`
+	Subject    *string    `json:"subject"`
+	Body       *string    `json:"body"`
+	OccurredAt *time.Time `json:"occurredAt"`
+}
+
+// NoteSearchResult is a note matched by full-text search, with its relevance.
+type NoteSearchResult struct {
+	Note
+	Score float64 `json:"score"`
+}
+
+const contactNotesDDL = `
+CREATE TABLE IF NOT EXISTS contact_notes (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  contact_id  BIGINT NOT NULL,
+  kind        VARCHAR(16)  NOT NULL,
+  author      VARCHAR(255) NOT NULL,
+  subject     VARCHAR(255),
+  body        TEXT NOT NULL,
+  occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_contact_timeline (contact_id, occurred_at, id),
+  FULLTEXT INDEX ft_note_text (subject, body),
+  FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+const noteColumns = `id, contact_id, kind, author, subject, body, occurred_at, created_at, updated_at`
+
+func createNote(w http.ResponseWriter, r *http.Request) {
+	contactID, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in NoteInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if in.Kind == "" {
+		in.Kind = noteKindNote
+	}
+	if err := validateNoteInput(in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	occurred := now
+	if in.OccurredAt != nil {
+		occurred = in.OccurredAt.UTC().Truncate(time.Second)
+	}
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	id, err := insertID(ctx, db, `
+INSERT INTO contact_notes (contact_id, kind, author, subject, body, occurred_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		contactID, in.Kind, strings.TrimSpace(in.Author), nullable(in.Subject), in.Body, occurred, now, now)
+	if err != nil {
+		if isForeignKeyErr(err) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", contactID))
+			return
+		}
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Note{
+		ID:         id,
+		ContactID:  contactID,
+		Kind:       in.Kind,
+		Author:     strings.TrimSpace(in.Author),
+		Subject:    in.Subject,
+		Body:       in.Body,
+		OccurredAt: occurred,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+}
+
+func getNote(w http.ResponseWriter, r *http.Request) {
+	contactID, noteID, err := parseNoteParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	n, err := scanNote(db.QueryRowContext(ctx, `
+SELECT `+noteColumns+`
+FROM contact_notes WHERE id = ? AND contact_id = ?`, noteID, contactID))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("note %d not found", noteID))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func patchNote(w http.ResponseWriter, r *http.Request) {
+	contactID, noteID, err := parseNoteParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in PartialNote
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validateNotePatch(in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	fields := make([]string, 0, 5)
+	args := make([]any, 0, 7)
+	if in.Kind != nil {
+		fields = append(fields, "kind = ?")
+		args = append(args, *in.Kind)
+	}
+	if in.Subject != nil {
+		fields = append(fields, "subject = ?")
+		args = append(args, nullable(in.Subject))
+	}
+	if in.Body != nil {
+		fields = append(fields, "body = ?")
+		args = append(args, *in.Body)
+	}
+	if in.OccurredAt != nil {
+		fields = append(fields, "occurred_at = ?")
+		args = append(args, in.OccurredAt.UTC().Truncate(time.Second))
+	}
+	if len(fields) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no updatable fields provided"))
+		return
+	}
+	fields = append(fields, "updated_at = ?")
+	args = append(args, time.Now().UTC().Truncate(time.Second), noteID, contactID)
+
+	q := fmt.Sprintf("UPDATE contact_notes SET %s WHERE id = ? AND contact_id = ?", strings.Join(fields, ", "))
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("note %d not found", noteID))
+		return
+	}
+	getNote(w, r)
+}
+
+func deleteNote(w http.ResponseWriter, r *http.Request) {
+	contactID, noteID, err := parseNoteParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `DELETE FROM contact_notes WHERE id = ? AND contact_id = ?`, noteID, contactID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("note %d not found", noteID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getTimeline lists a contact's notes and interactions, newest first.
+// Optional ?kind=call,meeting narrows the entry kinds.
+func getTimeline(w http.ResponseWriter, r *http.Request) {
+	contactID, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	page := parseIntDefault(r.URL.Query().Get("page"), 1)
+	pageSize := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("pageSize"), 0))
+	if page < 1 {
+		page = 1
+	}
+
+	where := "contact_id = ?"
+	args := []any{contactID}
+	if s := r.URL.Query().Get("kind"); s != "" {
+		kinds := strings.Split(s, ",")
+		placeholders := make([]string, len(kinds))
+		for i, k := range kinds {
+			k = strings.TrimSpace(k)
+			if !isNoteKind(k) {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid kind %q", k))
+				return
+			}
+			placeholders[i] = "?"
+			args = append(args, k)
+		}
+		where += " AND kind IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	var total int
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM contact_notes WHERE `+where, args...).Scan(&total); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if total == 0 {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = ?)`, contactID).Scan(&exists); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", contactID))
+			return
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+SELECT `+noteColumns+`
+FROM contact_notes
+WHERE `+where+`
+ORDER BY occurred_at DESC, id DESC
+LIMIT ? OFFSET ?`, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []Note{}
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		items = append(items, n)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"page":     page,
+		"pageSize": pageSize,
+		"total":    total,
+		"items":    items,
+	})
+}
+
+// searchNotes runs a full-text search over note subjects and bodies,
+// optionally restricted to one contact with ?contactId=.
+func searchNotes(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("q is required"))
+		return
+	}
+	page := parseIntDefault(r.URL.Query().Get("page"), 1)
+	pageSize := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("pageSize"), 0))
+	if page < 1 {
+		page = 1
+	}
+
+	match, score := dialect.noteSearch()
+	where := match
+	args := []any{q, q}
+	if s := r.URL.Query().Get("contactId"); s != "" {
+		contactID, err := parseIDParam(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		where += " AND contact_id = ?"
+		args = append(args, contactID)
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT `+noteColumns+`, `+score+` AS score
+FROM contact_notes
+WHERE `+where+`
+ORDER BY score DESC, id DESC
+LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []NoteSearchResult{}
+	for rows.Next() {
+		var res NoteSearchResult
+		n := &res.Note
+		var subject sql.NullString
+		if err := rows.Scan(&n.ID, &n.ContactID, &n.Kind, &n.Author, &subject, &n.Body, &n.OccurredAt, &n.CreatedAt, &n.UpdatedAt, &res.Score); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if subject.Valid {
+			n.Subject = &subject.String
+		}
+		items = append(items, res)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    items,
+	})
+}
+
+// Helpers
+
+func scanNote(row rowScanner) (Note, error) {
+	var n Note
+	var subject sql.NullString
+	if err := row.Scan(&n.ID, &n.ContactID, &n.Kind, &n.Author, &subject, &n.Body, &n.OccurredAt, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		return n, err
+	}
+	if subject.Valid {
+		n.Subject = &subject.String
+	}
+	return n, nil
+}
+
+func parseNoteParams(r *http.Request) (contactID, noteID int64, err error) {
+	if contactID, err = parseIDParam(chi.URLParam(r, "id")); err != nil {
+		return 0, 0, err
+	}
+	if noteID, err = parseIDParam(chi.URLParam(r, "noteId")); err != nil {
+		return 0, 0, err
+	}
+	return contactID, noteID, nil
+}
+
+func validateNoteInput(in NoteInput) error {
+	if strings.TrimSpace(in.Author) == "" {
+		return fmt.Errorf("author is required")
+	}
+	return validateNotePatch(PartialNote{Kind: &in.Kind, Subject: in.Subject, Body: &in.Body})
+}
+
+// validateNotePatch checks the fields that are set. validateNoteInput uses
+// it too, so a note meets the same rules whether created or edited.
+func validateNotePatch(in PartialNote) error {
+	if in.Kind != nil && !isNoteKind(*in.Kind) {
+		return fmt.Errorf("invalid kind %q: must be one of %s", *in.Kind, strings.Join(noteKinds, ", "))
+	}
+	if in.Subject != nil && utf8.RuneCountInString(*in.Subject) > 255 {
+		return fmt.Errorf("subject must be at most 255 characters")
+	}
+	if in.Body != nil {
+		if strings.TrimSpace(*in.Body) == "" {
+			return fmt.Errorf("body is required")
+		}
+		if len(*in.Body) > maxNoteBodyLen {
+			return fmt.Errorf("body must be at most %d bytes", maxNoteBodyLen)
+		}
+	}
+	return nil
+}
+
+func isNoteKind(k string) bool {
+	return slices.Contains(noteKinds, k)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"my-go-api/client"
+)
+
+func TestPatchNoteValidatesLikeCreate(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		var n Note
+		if status := do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/notes", id), client.NoteInput{Kind: client.NoteInputKindNote, Author: "sam", Body: "Met at the conference"}, &n); status != http.StatusCreated {
+			t.Fatalf("create note: status %d", status)
+		}
+		long := strings.Repeat("x", 256)
+		for _, in := range []client.NotePatch{{Subject: &long}, {Body: ptr("")}, {Kind: ptr(client.NotePatchKind(""))}} {
+			if status := do(t, h, http.MethodPatch, fmt.Sprintf("/contacts/%d/notes/%d", id, n.ID), in, nil); status != http.StatusUnprocessableEntity {
+				t.Errorf("patch %+v: status %d, want 422", in, status)
+			}
+		}
+	})
+}
+
+func TestTimelineOfMissingContact(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		if status := do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d/timeline", id), nil, nil); status != http.StatusOK {
+			t.Errorf("empty timeline: status %d, want 200", status)
+		}
+		if status := do(t, h, http.MethodGet, fmt.Sprintf("/contacts/%d/timeline", id+1000), nil, nil); status != http.StatusNotFound {
+			t.Errorf("missing contact: status %d, want 404", status)
+		}
+	})
+}
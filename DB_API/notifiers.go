@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notifier delivers a due reminder somewhere a human will see it.
+type Notifier interface {
+	Notify(ctx context.Context, rem DueReminder) error
+}
+
+// newNotifier picks the reminder notifier from reminders.notifier
+// (REMINDER_NOTIFIER):
+//
+//	log     (default) write to the process log
+//	webhook POST JSON to reminders.webhookURL (REMINDER_WEBHOOK_URL)
+//	email   send via SMTP at reminders.smtpAddr (REMINDER_SMTP_ADDR, default
+//	        localhost:1025, e.g. MailHog) from reminders.emailFrom
+//	        (REMINDER_EMAIL_FROM) to reminders.emailTo (REMINDER_EMAIL_TO)
+func newNotifier(c RemindersConfig) (Notifier, error) {
+	switch c.Notifier {
+	case "log":
+		return logNotifier{}, nil
+	case "webhook":
+		return &webhookNotifier{URL: c.WebhookURL, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case "email":
+		return &smtpNotifier{Addr: c.SMTPAddr, From: c.EmailFrom, To: c.EmailTo}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q: must be log, webhook or email", c.Notifier)
+	}
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, rem DueReminder) error {
+	slog.Info("reminder due", "reminder_id", rem.ID, "title", rem.Title,
+		"contact", rem.ContactName, "email", rem.ContactEmail, "due_at", rem.DueAt)
+	return nil
+}
+
+type webhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, rem DueReminder) error {
+	body, err := json.Marshal(map[string]any{"event": "reminder.due", "reminder": rem})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status %s", n.URL, resp.Status)
+	}
+	return nil
+}
+
+// smtpTimeout bounds one delivery by smtpNotifier, as the webhook
+// notifier's client timeout does.
+const smtpTimeout = 10 * time.Second
+
+// smtpNotifier sends plain-text mail without auth, which suits a local SMTP
+// stand-in such as MailHog or smtp4dev.
+type smtpNotifier struct {
+	Addr string
+	From string
+	To   string
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, rem DueReminder) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+rem.Title))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Follow up with %s <%s>.\r\n", rem.ContactName, rem.ContactEmail)
+	fmt.Fprintf(&msg, "Due: %s\r\n", rem.DueAt.Format(time.RFC1123))
+	if rem.Notes != nil {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", *rem.Notes)
+	}
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	if err := n.send(ctx, strings.Split(n.To, ","), []byte(msg.String())); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("smtp %s: %w", n.Addr, ctx.Err())
+		}
+		return err
+	}
+	return nil
+}
+
+// send does what smtp.SendMail does, on a connection that is closed when
+// ctx is done, so that a server that stops answering cannot hold up the
+// scheduler or shutdown.
+func (n *smtpNotifier) send(ctx context.Context, to []string, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(n.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
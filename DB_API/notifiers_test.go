@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one connection and speaks just enough SMTP to take a
+// message, which it sends on the returned channel. With hang set it accepts
+// the connection and never answers.
+func fakeSMTP(t *testing.T, hang bool) (addr string, got <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	msgs := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if hang {
+			_, _ = conn.Read(make([]byte, 1))
+			return
+		}
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case "MAIL", "RCPT":
+				_ = tp.PrintfLine("250 ok")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				body, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msgs <- string(body)
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), msgs
+}
+
+func TestSMTPNotifierSends(t *testing.T) {
+	addr, got := fakeSMTP(t, false)
+	n := &smtpNotifier{Addr: addr, From: "crm@example.com", To: "sam@example.com"}
+	rem := DueReminder{Reminder: Reminder{ID: 1, Title: "Follow up", DueAt: time.Now()}, ContactName: "Ada Lovelace", ContactEmail: "ada@example.com"}
+	if err := n.Notify(context.Background(), rem); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if !strings.Contains(msg, "Subject: Reminder: Follow up") || !strings.Contains(msg, "Ada Lovelace <ada@example.com>") {
+			t.Errorf("message:\n%s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered")
+	}
+}
+
+func TestSMTPNotifierStopsWithContext(t *testing.T) {
+	addr, _ := fakeSMTP(t, true)
+	n := &smtpNotifier{Addr: addr, From: "crm@example.com", To: "sam@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := n.Notify(ctx, DueReminder{Reminder: Reminder{ID: 1, Title: "Follow up"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("took %s to give up on a server that does not answer", took)
+	}
+}
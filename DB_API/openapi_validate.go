@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// openapi.validation (OPENAPI_VALIDATION) modes. Requests are validated unless it is "off";
+// "responses" also checks what handlers send back and logs drift, and
+// "strict" replaces a drifting response with a 500 so that a test run
+// against the server fails loudly.
+const (
+	validateOff       = "off"
+	validateRequests  = "requests"
+	validateResponses = "responses"
+	validateStrict    = "strict"
+)
+
+var openapiRouter routers.Router
+
+// initOpenAPIValidation builds the router even when validation is off, as
+// the mode can be changed by a reload.
+func initOpenAPIValidation() error {
+	// Errors are returned to clients; keep them to one line.
+	openapi3.SchemaErrorDetailsDisabled = true
+	// Match on paths only: servers lists an example host, not ours.
+	doc := *openapiDoc
+	doc.Servers = openapi3.Servers{{URL: "/"}}
+	router, err := gorillamux.NewRouter(&doc)
+	if err != nil {
+		return fmt.Errorf("openapi router: %w", err)
+	}
+	openapiRouter = router
+	return nil
+}
+
+// validateOpenAPI checks requests for routes in openapi.yaml before they
+// reach the handler. Parameter and malformed-body errors are 400s, as
+// decodeJSON and parseIDParam report them; body values that parse but break
+// a constraint (required, enum, minLength, ...) are 422s, like validateInput.
+// Routes not in the spec pass through untouched.
+func validateOpenAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := cfg().OpenAPI.Validation
+		if mode == validateOff {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The spec describes /contacts; /v1 and /v2 take the same requests.
+		specReq := r
+		if p := unversionedPath(r.URL.Path); p != r.URL.Path {
+			specReq = r.Clone(r.Context())
+			specReq.URL.Path, specReq.URL.RawPath = p, ""
+		}
+		route, pathParams, err := openapiRouter.FindRoute(specReq)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength != 0 && r.Header.Get("Content-Type") == "" {
+			// decodeJSON never required the header; keep accepting bodies without it.
+			r.Header.Set("Content-Type", "application/json")
+			specReq.Header.Set("Content-Type", "application/json")
+		}
+		in := &openapi3filter.RequestValidationInput{
+			Request:    specReq,
+			PathParams: pathParams,
+			Route:      route,
+		}
+		err = openapi3filter.ValidateRequest(r.Context(), in)
+		r.Body = specReq.Body // validation read the body and replaced it
+		if err != nil {
+			status, err := requestValidationError(err)
+			writeError(w, status, err)
+			return
+		}
+		// Responses are checked for v1 only: the spec documents the v1 shapes.
+		v, verr := resolveAPIVersion(r)
+		if mode == validateRequests || streamsResponse(route.Operation) || verr != nil || v != defaultAPIVersion {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{header: w.Header().Clone(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if err := validateResponse(r, in, rec); err != nil {
+			slog.WarnContext(r.Context(), "openapi: response drifts from spec", "method", r.Method, "path", r.URL.Path, "err", err)
+			if mode == validateStrict {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("response does not match openapi.yaml: %v", err))
+				return
+			}
+		}
+		rec.flush(w)
+	})
+}
+
+func requestValidationError(err error) (int, error) {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return http.StatusBadRequest, err
+	}
+	if p := reqErr.Parameter; p != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid %s parameter %q: %s", p.In, p.Name, validationReason(reqErr))
+	}
+	var schemaErr *openapi3.SchemaError
+	if reqErr.RequestBody == nil || !errors.As(reqErr.Err, &schemaErr) {
+		return http.StatusBadRequest, fmt.Errorf("invalid request body: %s", validationReason(reqErr))
+	}
+	field := strings.Join(schemaErr.JSONPointer(), ".")
+	switch schemaErr.SchemaField {
+	case "required":
+		return http.StatusUnprocessableEntity, fmt.Errorf("%s is required", field)
+	case "type", "properties": // wrong JSON type or unknown field, as decodeJSON reports them
+		if field == "" {
+			return http.StatusBadRequest, fmt.Errorf("invalid request body: %s", schemaErr.Reason)
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid request body: %s: %s", field, schemaErr.Reason)
+	}
+	return http.StatusUnprocessableEntity, fmt.Errorf("%s: %s", field, schemaErr.Reason)
+}
+
+func validationReason(reqErr *openapi3filter.RequestError) string {
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+	switch {
+	case errors.As(reqErr.Err, &schemaErr):
+		return schemaErr.Reason
+	case errors.As(reqErr.Err, &parseErr):
+		return parseErr.Error()
+	case reqErr.Err != nil:
+		return reqErr.Err.Error()
+	}
+	return reqErr.Reason
+}
+
+// streamsResponse reports whether an operation upgrades the connection or
+// streams (SSE), neither of which can be buffered for validation.
+func streamsResponse(op *openapi3.Operation) bool {
+	if op.Responses.Value("101") != nil {
+		return true
+	}
+	for _, ref := range op.Responses.Map() {
+		if ref.Value != nil && ref.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func validateResponse(r *http.Request, in *openapi3filter.RequestValidationInput, rec *responseRecorder) error {
+	opts := &openapi3filter.Options{IncludeResponseStatus: true}
+	if mt, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type")); mt != "application/json" {
+		opts.ExcludeResponseBody = true
+	}
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: in,
+		Status:                 rec.status,
+		Header:                 rec.header,
+		Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		Options:                opts,
+	})
+}
+
+// responseRecorder buffers a response so it can be checked before sending.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header         { return rec.header }
+func (rec *responseRecorder) WriteHeader(status int)      { rec.status = status }
+func (rec *responseRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+
+func (rec *responseRecorder) flush(w http.ResponseWriter) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestStrictValidationPassesHandlers runs requests through every kind of
+// operation in openapi.yaml with openapi.validation=strict. Any response
+// that drifts from the spec comes back as a 500.
+func TestStrictValidationPassesHandlers(t *testing.T) {
+	withConfig(t, func(c *Config) { c.OpenAPI.Validation = validateStrict })
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		var ids struct {
+			ada, grace, note, reminder, rel int64
+		}
+		check := func(method, path string, body any, want int, out any) {
+			t.Helper()
+			var errBody struct{ Error string }
+			if out == nil {
+				out = &errBody
+			}
+			if got := do(t, h, method, path, body, out); got != want {
+				t.Errorf("%s %s: status %d, want %d (%s)", method, path, got, want, errBody.Error)
+			}
+		}
+		var id struct{ ID int64 }
+
+		check(http.MethodPost, "/custom-fields", map[string]any{"name": "tier", "type": "enum", "rules": map[string]any{"options": []string{"gold", "silver"}}}, http.StatusCreated, nil)
+		check(http.MethodGet, "/custom-fields", nil, http.StatusOK, nil)
+		check(http.MethodGet, "/custom-fields/tier", nil, http.StatusOK, nil)
+
+		check(http.MethodPost, "/contacts", map[string]any{"firstName": "Ada", "lastName": "Lovelace", "email": "ada@example.com", "company": "Analytical", "customFields": map[string]any{"tier": "gold"}}, http.StatusCreated, &id)
+		ids.ada = id.ID
+		check(http.MethodPost, "/contacts", map[string]any{"firstName": "Grace", "lastName": "Hopper", "email": "grace@example.com", "phone": "555-0100"}, http.StatusCreated, &id)
+		ids.grace = id.ID
+		ada := fmt.Sprintf("/contacts/%d", ids.ada)
+		grace := fmt.Sprintf("/contacts/%d", ids.grace)
+
+		check(http.MethodPost, "/contacts", map[string]any{"firstName": "Ada", "lastName": "Byron", "email": "ada@example.com"}, http.StatusConflict, nil)
+		check(http.MethodGet, "/contacts", nil, http.StatusOK, nil)
+		check(http.MethodGet, "/contacts?search=love&sort=lastName&cf.tier=gold", nil, http.StatusOK, nil)
+		check(http.MethodGet, ada, nil, http.StatusOK, nil)
+		check(http.MethodGet, fmt.Sprintf("/contacts/%d", ids.ada+1000), nil, http.StatusNotFound, nil)
+		check(http.MethodGet, "/contacts/0", nil, http.StatusBadRequest, nil)
+		check(http.MethodPut, ada, map[string]any{"firstName": "Ada", "lastName": "King", "email": "ada@example.com", "customFields": map[string]any{"tier": "silver"}}, http.StatusOK, nil)
+		check(http.MethodPatch, ada, map[string]any{"phone": "555-0199"}, http.StatusOK, nil)
+		check(http.MethodPatch, ada, map[string]any{"email": "not-an-email"}, http.StatusUnprocessableEntity, nil)
+
+		check(http.MethodPost, ada+"/notes", map[string]any{"kind": "call", "author": "sam", "subject": "Renewal", "body": "Called about renewal"}, http.StatusCreated, &id)
+		ids.note = id.ID
+		note := fmt.Sprintf("%s/notes/%d", ada, ids.note)
+		check(http.MethodGet, note, nil, http.StatusOK, nil)
+		check(http.MethodPatch, note, map[string]any{"body": "Called twice"}, http.StatusOK, nil)
+		check(http.MethodGet, ada+"/timeline", nil, http.StatusOK, nil)
+		check(http.MethodGet, "/notes/search?q=twice", nil, http.StatusOK, nil)
+
+		check(http.MethodPost, ada+"/reminders", map[string]any{"title": "Follow up", "dueIn": "2w", "recurrence": "monthly"}, http.StatusCreated, &id)
+		ids.reminder = id.ID
+		reminder := fmt.Sprintf("/reminders/%d", ids.reminder)
+		check(http.MethodGet, ada+"/reminders", nil, http.StatusOK, nil)
+		check(http.MethodGet, "/reminders/due", nil, http.StatusOK, nil)
+		check(http.MethodGet, reminder, nil, http.StatusOK, nil)
+		check(http.MethodPatch, reminder, map[string]any{"title": "Follow up again"}, http.StatusOK, nil)
+		check(http.MethodPost, reminder+"/complete", nil, http.StatusOK, nil)
+
+		check(http.MethodPost, ada+"/relationships", map[string]any{"toId": ids.grace, "type": "colleague_of", "bidirectional": true}, http.StatusCreated, &id)
+		ids.rel = id.ID
+		check(http.MethodGet, ada+"/relationships", nil, http.StatusOK, nil)
+		check(http.MethodGet, ada+"/network?depth=2", nil, http.StatusOK, nil)
+		check(http.MethodDelete, fmt.Sprintf("%s/relationships/%d", ada, ids.rel), nil, http.StatusNoContent, nil)
+		check(http.MethodGet, ada+"/relationships", nil, http.StatusOK, nil)
+		check(http.MethodGet, fmt.Sprintf("/contacts/%d/relationships", ids.ada+1000), nil, http.StatusNotFound, nil)
+
+		check(http.MethodGet, "/contacts/duplicates", nil, http.StatusOK, nil)
+		check(http.MethodGet, "/contacts/duplicates/scan", nil, http.StatusOK, nil)
+		check(http.MethodPost, grace+"/merge", map[string]any{"sourceId": ids.ada}, http.StatusOK, nil)
+		check(http.MethodGet, grace+"/merges", nil, http.StatusOK, nil)
+
+		check(http.MethodDelete, fmt.Sprintf("%s/notes/%d", grace, ids.note), nil, http.StatusNoContent, nil)
+		check(http.MethodDelete, reminder, nil, http.StatusNoContent, nil)
+		check(http.MethodDelete, grace, nil, http.StatusNoContent, nil)
+		check(http.MethodDelete, "/custom-fields/tier", nil, http.StatusNoContent, nil)
+	})
+}
+
+// TestStrictValidationRejectsDrift stores a note kind the spec does not
+// allow, as an older or newer release might have, and reads it back.
+func TestStrictValidationRejectsDrift(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		var n Note
+		if status := do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/notes", id), map[string]any{"kind": "note", "author": "sam", "body": "Hello"}, &n); status != http.StatusCreated {
+			t.Fatalf("create note: status %d", status)
+		}
+		if _, err := db.Exec(`UPDATE contact_notes SET kind = 'fax' WHERE id = ?`, n.ID); err != nil {
+			t.Fatal(err)
+		}
+		path := fmt.Sprintf("/contacts/%d/notes/%d", id, n.ID)
+		for _, tt := range []struct {
+			mode string
+			want int
+		}{
+			{validateRequests, http.StatusOK},
+			{validateResponses, http.StatusOK}, // logged only
+			{validateStrict, http.StatusInternalServerError},
+		} {
+			t.Run(tt.mode, func(t *testing.T) {
+				withConfig(t, func(c *Config) { c.OpenAPI.Validation = tt.mode })
+				var body struct {
+					Kind  string
+					Error string
+				}
+				if status := do(t, h, http.MethodGet, path, nil, &body); status != tt.want {
+					t.Fatalf("status %d, want %d", status, tt.want)
+				}
+				if tt.want == http.StatusOK && body.Kind != "fax" {
+					t.Errorf("kind = %q, want the stored fax", body.Kind)
+				}
+				if tt.want != http.StatusOK && !strings.Contains(body.Error, "does not match openapi.yaml") {
+					t.Errorf("error = %q, want it to name the drift", body.Error)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// The outbox holds every contact event, written in the same transaction as the
+// change it describes. A relay goroutine per sink reads it in seq order and
+// remembers its position in outbox_offsets, so each sink gets every committed
+// event at least once even if the process dies between commit and publish.
+const eventOutboxDDL = `
+CREATE TABLE IF NOT EXISTS event_outbox (
+  seq BIGINT AUTO_INCREMENT PRIMARY KEY,
+  event_id   VARCHAR(64) NOT NULL UNIQUE,
+  event_type VARCHAR(64) NOT NULL,
+  contact_id BIGINT NOT NULL,
+  payload    MEDIUMTEXT NOT NULL,
+  created_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+const outboxOffsetsDDL = `
+CREATE TABLE IF NOT EXISTS outbox_offsets (
+  sink       VARCHAR(64) PRIMARY KEY,
+  last_seq   BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// EventSink is a destination for outbox events. Publish must be safe to call
+// again with an event it has already seen; sinks should pass Event.ID along as
+// an idempotency key so that downstream consumers can drop duplicates.
+type EventSink interface {
+	Name() string
+	Publish(ctx context.Context, evt Event) error
+}
+
+// recordContactEvent writes a lifecycle event to the outbox inside tx. The
+// event only becomes visible to the relay if tx commits. Every contact
+// change passes through here, so it also issues the Consistency-Token.
+func recordContactEvent(ctx context.Context, tx *sql.Tx, typ string, contactID int64, data any) error {
+	markContactWrite(ctx)
+	evt, err := newEvent(typ, contactID, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO event_outbox (event_id, event_type, contact_id, payload, created_at)
+VALUES (?, ?, ?, ?, ?)`, evt.ID, evt.Type, evt.ContactID, string(payload), evt.OccurredAt)
+	return err
+}
+
+type outboxRelay struct {
+	sinks        []EventSink
+	BatchSize    int
+	PollInterval time.Duration
+	// GapTimeout bounds how long the relay waits on a hole in the seq order.
+	// AUTO_INCREMENT values are handed out at insert time, so a lower seq can
+	// commit after a higher one; skipping ahead too early would lose it. Holes
+	// left by rolled-back transactions are passed once they are this old.
+	GapTimeout time.Duration
+	// Retention is how long fully relayed events are kept before pruning.
+	Retention time.Duration
+	// PublishTimeout bounds each Publish call.
+	PublishTimeout time.Duration
+
+	wake map[string]chan struct{}
+}
+
+var relay = &outboxRelay{
+	BatchSize:      100,
+	PollInterval:   time.Second,
+	GapTimeout:     30 * time.Second,
+	Retention:      24 * time.Hour,
+	PublishTimeout: 10 * time.Second,
+}
+
+// useSinks configures the sinks; call it before run and before serving requests.
+func (o *outboxRelay) useSinks(sinks []EventSink) {
+	o.sinks = sinks
+	o.wake = make(map[string]chan struct{}, len(sinks))
+	for _, s := range sinks {
+		o.wake[s.Name()] = make(chan struct{}, 1)
+	}
+}
+
+// notify wakes every sink loop; handlers call it right after committing.
+func (o *outboxRelay) notify() {
+	for _, ch := range o.wake {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run starts one relay loop per sink plus the pruner and blocks until ctx is cancelled.
+func (o *outboxRelay) run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, s := range o.sinks {
+		go func(s EventSink) {
+			o.runSink(ctx, s, o.wake[s.Name()])
+			done <- struct{}{}
+		}(s)
+	}
+
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		if err := o.prune(ctx); err != nil {
+			slog.Error("outbox: prune", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			for range o.sinks {
+				<-done
+			}
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (o *outboxRelay) runSink(ctx context.Context, s EventSink, wake <-chan struct{}) {
+	initCtx, cancel := dbCtx(ctx)
+	if _, err := db.ExecContext(initCtx, dialect.insertIgnore(`INSERT INTO outbox_offsets (sink, last_seq) VALUES (?, 0)`), s.Name()); err != nil {
+		slog.Error("outbox: init offset", "sink", s.Name(), "err", err)
+	}
+	cancel()
+	const maxBackoff = time.Minute
+	backoff := o.PollInterval
+	for {
+		n, err := o.relayBatch(ctx, s)
+		wait := o.PollInterval
+		switch {
+		case err != nil:
+			slog.Error("outbox: relay", "sink", s.Name(), "err", err)
+			wait = backoff
+			backoff = min(backoff*2, maxBackoff)
+		case n == o.BatchSize:
+			backoff = o.PollInterval
+			continue // more to drain
+		default:
+			backoff = o.PollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+type outboxEntry struct {
+	seq       int64
+	createdAt time.Time
+	evt       Event
+}
+
+// relayBatch publishes the next batch for s, in order, and advances its
+// offset past whatever was published. No lock or transaction is held while
+// publishing: each query and each Publish has its own deadline, so a slow
+// sink only delays the batch. The offset only moves from the value the batch
+// was read at, so if replicas running the same relay race, one advances it
+// and the others' events were duplicates, which sinks already tolerate.
+func (o *outboxRelay) relayBatch(ctx context.Context, s EventSink) (int, error) {
+	readCtx, cancel := dbCtx(ctx)
+	defer cancel()
+	var from int64
+	if err := db.QueryRowContext(readCtx, `SELECT last_seq FROM outbox_offsets WHERE sink = ?`, s.Name()).Scan(&from); err != nil {
+		return 0, err
+	}
+	batch, err := readOutbox(readCtx, db, from, o.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	cancel()
+
+	last := from
+	published := 0
+	var pubErr error
+	for _, e := range batch {
+		if !gapSettled(e, last, o.GapTimeout) {
+			break
+		}
+		pubCtx, cancel := context.WithTimeout(ctx, o.PublishTimeout)
+		pubErr = s.Publish(pubCtx, e.evt)
+		cancel()
+		if pubErr != nil {
+			break
+		}
+		last = e.seq
+		published++
+	}
+	if published > 0 {
+		updCtx, cancel := dbCtx(ctx)
+		defer cancel()
+		if _, err := db.ExecContext(updCtx, `UPDATE outbox_offsets SET last_seq = ? WHERE sink = ? AND last_seq = ?`, last, s.Name(), from); err != nil {
+			return published, errors.Join(pubErr, err)
+		}
+	}
+	return published, pubErr
+}
+
+// readOutbox returns up to limit events after seq, in order.
+func readOutbox(ctx context.Context, q queryer, after int64, limit int) ([]outboxEntry, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := q.QueryContext(ctx, `
+SELECT seq, payload, created_at
+FROM event_outbox
+WHERE seq > ?
+ORDER BY seq
+LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []outboxEntry
+	for rows.Next() {
+		var e outboxEntry
+		var payload string
+		if err := rows.Scan(&e.seq, &payload, &e.createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &e.evt); err != nil {
+			return nil, err
+		}
+		batch = append(batch, e)
+	}
+	return batch, rows.Err()
+}
+
+// gapSettled reports whether e may be consumed after last. If seqs are not
+// contiguous an earlier transaction may still commit into the gap, so e is
+// held back until it is older than timeout.
+func gapSettled(e outboxEntry, last int64, timeout time.Duration) bool {
+	return e.seq == last+1 || time.Since(e.createdAt) >= timeout
+}
+
+// prune deletes events every sink has relayed once they are older than Retention.
+func (o *outboxRelay) prune(ctx context.Context) error {
+	if len(o.sinks) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(o.sinks))
+	args := make([]any, len(o.sinks))
+	for i, s := range o.sinks {
+		placeholders[i] = "?"
+		args[i] = s.Name()
+	}
+	var minSeq sql.NullInt64
+	var count int
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	if err := db.QueryRowContext(ctx, `SELECT MIN(last_seq), COUNT(*) FROM outbox_offsets WHERE sink IN (`+strings.Join(placeholders, ", ")+`)`, args...).
+		Scan(&minSeq, &count); err != nil {
+		return err
+	}
+	if count < len(o.sinks) || !minSeq.Valid {
+		return nil // some sink has not started yet
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM event_outbox WHERE seq <= ? AND created_at < ?`,
+		minSeq.Int64, time.Now().UTC().Add(-o.Retention))
+	return err
+}
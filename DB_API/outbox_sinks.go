@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// newSinks builds the outbox sinks listed in outbox.sinks (OUTBOX_SINKS,
+// comma-separated, default "webhook"):
+//
+//	webhook fan out to the webhook subscriptions (see webhooks.go)
+//	stdout  print each event as a JSON line
+//	nats    publish to JetStream at outbox.natsURL (NATS_URL, default
+//	        nats://127.0.0.1:4222) on "<prefix>.<event type>", where the prefix
+//	        is outbox.natsSubjectPrefix (NATS_SUBJECT_PREFIX, default
+//	        "contacts"); a stream must cover that subject
+func newSinks(c OutboxConfig) ([]EventSink, error) {
+	var sinks []EventSink
+	seen := make(map[string]bool)
+	for _, name := range c.Sinks {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "webhook":
+			sinks = append(sinks, webhookSink{})
+		case "stdout":
+			sinks = append(sinks, &writerSink{name: "stdout", w: os.Stdout})
+		case "nats":
+			s, err := newNATSSink(c.NATSURL, c.NATSSubjectPrefix)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, s)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q: must be webhook, stdout or nats", name)
+		}
+	}
+	return sinks, nil
+}
+
+// webhookSink hands events to the webhook dispatcher. Deliveries are keyed on
+// (subscription, event id), so re-publishing an event is a no-op.
+type webhookSink struct{}
+
+func (webhookSink) Name() string { return "webhook" }
+
+func (webhookSink) Publish(ctx context.Context, evt Event) error {
+	return webhooks.enqueue(ctx, evt)
+}
+
+// writerSink writes one JSON line per event. Consumers de-duplicate on "id".
+type writerSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func (s *writerSink) Name() string { return s.name }
+
+func (s *writerSink) Publish(_ context.Context, evt Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.NewEncoder(s.w).Encode(evt)
+}
+
+// natsSink publishes to JetStream and waits for the server ack. The event id
+// is sent as Nats-Msg-Id, which JetStream uses to drop duplicates within the
+// stream's duplicate window.
+type natsSink struct {
+	nc     *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+func newNATSSink(url, prefix string) (*natsSink, error) {
+	nc, err := nats.Connect(url, nats.Name("contacts-api outbox"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("nats connect %s: %w", url, err)
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats jetstream: %w", err)
+	}
+	return &natsSink{nc: nc, js: js, prefix: prefix}, nil
+}
+
+func (s *natsSink) Name() string { return "nats" }
+
+func (s *natsSink) Publish(ctx context.Context, evt Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.prefix + "." + evt.Type)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, evt.ID)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = s.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowSink takes delay over each event.
+type slowSink struct {
+	delay time.Duration
+	got   []Event
+}
+
+func (*slowSink) Name() string { return "slow" }
+
+func (s *slowSink) Publish(ctx context.Context, evt Event) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.got = append(s.got, evt)
+	return nil
+}
+
+// TestRelayBatchOutlastsQueryTimeout relays a batch that takes longer to
+// publish than db.queryTimeout allows a single query.
+func TestRelayBatchOutlastsQueryTimeout(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DB.QueryTimeout = 300 * time.Millisecond })
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		for _, email := range []string{"ada@example.com", "grace@example.com", "hedy@example.com"} {
+			createTestContact(t, h, "Test", email)
+		}
+		ctx := context.Background()
+		sink := &slowSink{delay: 150 * time.Millisecond}
+		if _, err := db.ExecContext(ctx, dialect.insertIgnore(`INSERT INTO outbox_offsets (sink, last_seq) VALUES (?, 0)`), sink.Name()); err != nil {
+			t.Fatal(err)
+		}
+		// Seqs carry on from earlier tests, so the first one follows a gap.
+		o := &outboxRelay{BatchSize: 10, PublishTimeout: time.Second}
+
+		n, err := o.relayBatch(ctx, sink)
+		if err != nil || n != 3 || len(sink.got) != 3 {
+			t.Fatalf("relayed %d (%d published), %v; want 3", n, len(sink.got), err)
+		}
+		if n, err := o.relayBatch(ctx, sink); err != nil || n != 0 {
+			t.Errorf("second batch relayed %d, %v; want the offset past all 3", n, err)
+		}
+
+		// A sink slower than PublishTimeout fails the batch without moving the offset.
+		o.PublishTimeout = 50 * time.Millisecond
+		createTestContact(t, h, "Test", "katherine@example.com")
+		if n, err := o.relayBatch(ctx, sink); err == nil || n != 0 {
+			t.Errorf("timed-out publish: relayed %d, %v; want an error", n, err)
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Rate limiting is a token bucket per client and policy. rateLimit.default
+// (RATE_LIMIT) is the policy for every route; rateLimit.routes
+// (RATE_LIMIT_ROUTES) overrides it for individual routes, each of which then
+// gets its own buckets:
+//
+//	RATE_LIMIT=600/m
+//	RATE_LIMIT_ROUTES="POST /contacts=30/m:60; /contacts/{id}/merge=5/m; GET /contacts/events=off"
+//
+// A policy is <limit>/<period>[:<burst>]: limit requests are let through
+// per period (s, m, h or a Go duration such as 10s), with up to burst (by
+// default limit) at once. Routes are chi patterns without the /v1 or /v2
+// prefix, optionally preceded by a method. Policies can be changed with a
+// SIGHUP; the backend (memory or redis) cannot.
+//
+// Buckets are per client IP unless rateLimit.by (RATE_LIMIT_BY) names other
+// identities to prefer. Headers are only trusted once verified: "apikey"
+// keys on an X-API-Key listed in rateLimit.apiKeys (RATE_LIMIT_API_KEYS,
+// the key's SHA-256 in hex = the client it belongs to) and "user" on that
+// client, sharing one bucket across its keys. Any other key, and X-User,
+// which anyone can set, fall through to the next identity.
+const (
+	apiKeyHeader       = "X-API-Key"
+	defaultRateLimitBy = "ip"
+	rateLimitSweep     = time.Minute
+)
+
+type ratePolicy struct {
+	name   string // "default" or the route, used in bucket keys
+	limit  int    // requests per period
+	period time.Duration
+	burst  int     // bucket capacity
+	rate   float64 // tokens per second
+}
+
+// String renders the policy as a RateLimit-Policy header value.
+func (p *ratePolicy) String() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", p.limit, int(p.period.Seconds()), p.burst)
+}
+
+// rateDecision is the outcome of taking a token.
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration // until a token is available, when denied
+	reset      time.Duration // until the bucket is full again
+}
+
+// rateLimitStore keeps the buckets. take removes one token from the bucket
+// at key, refilling it first for the time since it was last used.
+type rateLimitStore interface {
+	take(ctx context.Context, key string, p *ratePolicy) (rateDecision, error)
+}
+
+// rateRules are the compiled policies.
+type rateRules struct {
+	fallback *ratePolicy            // nil: routes without a policy are not limited
+	routes   map[string]*ratePolicy // "METHOD /pattern" or "/pattern"; nil value: unlimited
+	keyBy    []string
+	apiKeys  map[string]string // SHA-256 hex of a known API key -> its client
+}
+
+type rateLimiter struct {
+	store rateLimitStore
+	rules atomic.Pointer[rateRules]
+}
+
+// newRateLimiter sets up the store for c.Backend with the rules validate
+// compiled. The limiter is installed even without rules so that a reload
+// can add some.
+func newRateLimiter(c RateLimitConfig) (*rateLimiter, error) {
+	l := &rateLimiter{}
+	switch c.Backend {
+	case "memory":
+		l.store = newMemoryRateStore()
+	case "redis":
+		s, err := newRedisRateStore(c.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		l.store = s
+	default:
+		return nil, fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	l.rules.Store(c.rules)
+	return l, nil
+}
+
+func parseRateRules(c RateLimitConfig) (*rateRules, error) {
+	rules := &rateRules{routes: make(map[string]*ratePolicy), apiKeys: make(map[string]string, len(c.APIKeys))}
+	if c.Default != "" && c.Default != "off" {
+		p, err := parseRatePolicy("default", c.Default)
+		if err != nil {
+			return nil, fmt.Errorf("rateLimit.default: %w", err)
+		}
+		rules.fallback = p
+	}
+	for route, spec := range c.Routes {
+		route = normalizeRateRoute(route)
+		if spec = strings.TrimSpace(spec); spec == "off" {
+			rules.routes[route] = nil
+			continue
+		}
+		p, err := parseRatePolicy(route, spec)
+		if err != nil {
+			return nil, fmt.Errorf("rateLimit.routes: %s: %w", route, err)
+		}
+		rules.routes[route] = p
+	}
+	for _, k := range c.By {
+		switch k = strings.TrimSpace(k); k {
+		case "apikey", "user", "ip":
+			rules.keyBy = append(rules.keyBy, k)
+		default:
+			return nil, fmt.Errorf("rateLimit.by: unknown key %q (want apikey, user or ip)", k)
+		}
+	}
+	for sum, client := range c.APIKeys {
+		sum = strings.ToLower(strings.TrimSpace(sum))
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("rateLimit.apiKeys: %q is not a SHA-256 in hex", sum)
+		}
+		if client = strings.TrimSpace(client); client == "" {
+			return nil, fmt.Errorf("rateLimit.apiKeys: %s...: client name is empty", sum[:8])
+		}
+		rules.apiKeys[sum] = client
+	}
+	return rules, nil
+}
+
+func parseRatePolicy(name, s string) (*ratePolicy, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	n, per, ok := strings.Cut(spec, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid policy %q: want <limit>/<period>[:<burst>]", s)
+	}
+	limit, err := strconv.Atoi(n)
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("invalid limit %q", n)
+	}
+	var period time.Duration
+	switch per {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(per)
+		if err != nil || period < time.Second {
+			return nil, fmt.Errorf("invalid period %q", per)
+		}
+	}
+	burst := limit
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("invalid burst %q", burstStr)
+		}
+	}
+	return &ratePolicy{
+		name:   name,
+		limit:  limit,
+		period: period,
+		burst:  burst,
+		rate:   float64(limit) / period.Seconds(),
+	}, nil
+}
+
+// normalizeRateRoute turns "post /v2/contacts/" into "POST /contacts".
+func normalizeRateRoute(route string) string {
+	method, pattern, ok := strings.Cut(strings.TrimSpace(route), " ")
+	if !ok {
+		method, pattern = "", method
+	}
+	pattern = unversionedPath(strings.TrimSpace(pattern))
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if method == "" {
+		return pattern
+	}
+	return strings.ToUpper(method) + " " + pattern
+}
+
+// policyFor returns the policy for a request, or nil if it is not limited.
+func (rules *rateRules) policyFor(mux *chi.Mux, r *http.Request) *ratePolicy {
+	if len(rules.routes) == 0 {
+		return rules.fallback
+	}
+	pattern := mux.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+	if pattern == "" {
+		return rules.fallback
+	}
+	route := normalizeRateRoute(pattern)
+	if p, ok := rules.routes[r.Method+" "+route]; ok {
+		return p
+	}
+	if p, ok := rules.routes[route]; ok {
+		return p
+	}
+	return rules.fallback
+}
+
+// clientKey identifies the caller by the first of keyBy it can verify. API
+// keys are hashed so that they never end up in Redis or logs.
+func (rules *rateRules) clientKey(r *http.Request) string {
+	var keySum, client string
+	if v := r.Header.Get(apiKeyHeader); v != "" && len(rules.apiKeys) > 0 {
+		sum := sha256.Sum256([]byte(v))
+		keySum = hex.EncodeToString(sum[:])
+		client = rules.apiKeys[keySum]
+	}
+	for _, k := range rules.keyBy {
+		switch k {
+		case "apikey":
+			if client != "" {
+				return "key:" + keySum[:16]
+			}
+		case "user":
+			if client != "" {
+				return "user:" + client
+			}
+		case "ip":
+			// middleware.RealIP has already replaced RemoteAddr if a proxy
+			// header was present.
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			return "ip:" + host
+		}
+	}
+	return "anonymous"
+}
+
+// middleware enforces the limits. It needs the router to find the route
+// pattern before chi has routed the request. When the store fails the
+// request is let through: an outage of the limiter should not take the API
+// down with it. Probes and /metrics are never limited.
+func (l *rateLimiter) middleware(mux *chi.Mux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isProbe(r.URL.Path) || r.URL.Path == metricsPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rules := l.rules.Load()
+			p := rules.policyFor(mux, r)
+			if p == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			d, err := l.store.take(r.Context(), p.name+"|"+rules.clientKey(r), p)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "ratelimit: store failed; allowing request", "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("RateLimit-Policy", p.String())
+			h.Set("RateLimit-Limit", strconv.Itoa(p.burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+			if !d.allowed {
+				h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.retryAfter), 1)))
+				writeError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded: %d requests per %s", p.limit, p.period))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeToken refills a bucket for elapsed seconds, then tries to take a token.
+func takeToken(tokens, elapsed float64, p *ratePolicy) (float64, rateDecision) {
+	tokens = math.Min(float64(p.burst), tokens+elapsed*p.rate)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return tokens, rateDecisionFor(allowed, tokens, p)
+}
+
+// rateDecisionFor describes a bucket left with tokens after a take.
+func rateDecisionFor(allowed bool, tokens float64, p *ratePolicy) rateDecision {
+	d := rateDecision{
+		allowed:   allowed,
+		remaining: int(tokens),
+		reset:     secondsDuration((float64(p.burst) - tokens) / p.rate),
+	}
+	if !allowed {
+		d.retryAfter = secondsDuration((1 - tokens) / p.rate)
+	}
+	return d
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket will have refilled completely
+}
+
+// memoryRateStore keeps buckets in this process, so each replica enforces
+// its own limits. Full buckets are dropped every rateLimitSweep.
+type memoryRateStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newMemoryRateStore() *memoryRateStore {
+	return &memoryRateStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+func (s *memoryRateStore) take(_ context.Context, key string, p *ratePolicy) (rateDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= rateLimitSweep {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(p.burst), last: now}
+		s.buckets[key] = b
+	}
+	var d rateDecision
+	b.tokens, d = takeToken(b.tokens, now.Sub(b.last).Seconds(), p)
+	b.last = now
+	b.full = now.Add(d.reset)
+	return d, nil
+}
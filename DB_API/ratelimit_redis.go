@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisRateKeyPrefix = "ratelimit:"
+
+// redisTakeScript is takeToken run inside Redis, so that every replica
+// shares one bucket per client and the update is atomic. It uses the
+// server's clock rather than the callers'. Buckets expire once they would
+// have refilled.
+//
+// KEYS[1] bucket, ARGV[1] tokens per second, ARGV[2] burst.
+// Returns {allowed (0/1), tokens left as a string}.
+var redisTakeScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.max(1000, math.ceil((burst - tokens) / rate * 1000)))
+return {allowed, tostring(tokens)}
+`)
+
+// redisRateStore keeps buckets in Redis or anything speaking its protocol
+// with Lua scripting (Valkey, KeyDB, miniredis for local runs).
+type redisRateStore struct {
+	client *redis.Client
+}
+
+// newRedisRateStore connects to url (REDIS_URL, default redis://localhost:6379/0).
+func newRedisRateStore(url string) (*redisRateStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("redis url: %w", err)
+	}
+	return &redisRateStore{client: redis.NewClient(opts)}, nil
+}
+
+func (s *redisRateStore) take(ctx context.Context, key string, p *ratePolicy) (rateDecision, error) {
+	res, err := redisTakeScript.Run(ctx, s.client, []string{redisRateKeyPrefix + key},
+		strconv.FormatFloat(p.rate, 'g', -1, 64), p.burst).Slice()
+	if err != nil {
+		return rateDecision{}, fmt.Errorf("redis: %w", err)
+	}
+	if len(res) != 2 {
+		return rateDecision{}, fmt.Errorf("redis: unexpected script result %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+	if err != nil {
+		return rateDecision{}, fmt.Errorf("redis: bad token count %v", res[1])
+	}
+	return rateDecisionFor(allowed == 1, tokens, p), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRateLimitClientKey(t *testing.T) {
+	const key = "k-123"
+	sum := sha256.Sum256([]byte(key))
+	keys := map[string]string{hex.EncodeToString(sum[:]): "billing"}
+	for _, tt := range []struct {
+		name    string
+		by      []string
+		headers map[string]string
+		want    string
+	}{
+		{"default", strings.Split(defaultRateLimitBy, ","), map[string]string{apiKeyHeader: key, headerUser: "grace"}, "ip:192.0.2.1"},
+		{"known key", []string{"apikey", "ip"}, map[string]string{apiKeyHeader: key}, "key:" + hex.EncodeToString(sum[:8])},
+		{"unknown key", []string{"apikey", "ip"}, map[string]string{apiKeyHeader: "made-up"}, "ip:192.0.2.1"},
+		{"client of a known key", []string{"user", "ip"}, map[string]string{apiKeyHeader: key}, "user:billing"},
+		{"claimed user", []string{"user", "ip"}, map[string]string{headerUser: "grace"}, "ip:192.0.2.1"},
+		{"no identity", []string{"apikey", "user"}, nil, "anonymous"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRateRules(RateLimitConfig{By: tt.by, APIKeys: keys})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/contacts", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := rules.clientKey(r); got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitAPIKeysConfig(t *testing.T) {
+	for _, keys := range []map[string]string{
+		{"k-123": "billing"},
+		{strings.Repeat("ab", sha256.Size): " "},
+	} {
+		if _, err := parseRateRules(RateLimitConfig{APIKeys: keys}); err == nil {
+			t.Errorf("apiKeys %v accepted", keys)
+		}
+	}
+}
+
+func TestRedisRateStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := newRedisRateStore("redis://" + mr.Addr() + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	p, err := parseRatePolicy("default", "60/m:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+
+	for i := range 3 {
+		d, err := store.take(ctx, "default|ip:a", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.allowed || d.remaining != 2-i {
+			t.Fatalf("take %d: %+v, want allowed with %d left", i+1, d, 2-i)
+		}
+	}
+	d, err := store.take(ctx, "default|ip:a", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.allowed || d.retryAfter <= 0 || d.retryAfter > time.Second {
+		t.Errorf("over the burst: %+v, want denied with a retry within a second", d)
+	}
+	if d, _ := store.take(ctx, "default|ip:b", p); !d.allowed {
+		t.Error("another client shares the bucket")
+	}
+	if ttl := mr.TTL(redisRateKeyPrefix + "default|ip:a"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("bucket TTL %s, want until it has refilled", ttl)
+	}
+
+	// One token a second.
+	mr.SetTime(now.Add(1500 * time.Millisecond))
+	if d, _ := store.take(ctx, "default|ip:a", p); !d.allowed {
+		t.Error("bucket did not refill")
+	}
+	if d, _ := store.take(ctx, "default|ip:a", p); d.allowed {
+		t.Error("bucket refilled more than one token")
+	}
+}
+
+func TestRateLimitMiddlewareRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rl := RateLimitConfig{Default: "2/m", By: strings.Split(defaultRateLimitBy, ","), Backend: "redis", RedisURL: "redis://" + mr.Addr() + "/0"}
+	rules, err := parseRateRules(rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.rules = rules
+	limiter, err := newRateLimiter(rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newRouter(limiter)
+	get := func(ip, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set(headerUser, user)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for i := range 2 {
+		if w := get("192.0.2.1", "grace"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, w.Code)
+		}
+	}
+	// Changing X-User does not buy a new bucket.
+	w := get("192.0.2.1", "someone-else")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("third request: status %d, Retry-After %q; want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := get("192.0.2.2", "grace"); w.Code != http.StatusOK {
+		t.Errorf("another IP: status %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// relationshipTypes maps each supported type to the label used when the
+// relationship is viewed from the other end ("A reports_to B" reads as
+// "B manages A"). Bidirectional relationships read the same both ways.
+var relationshipTypes = map[string]string{
+	"reports_to":   "manages",
+	"assistant_of": "has_assistant",
+	"referred_by":  "referred",
+	"colleague_of": "colleague_of",
+}
+
+const maxNetworkDepth = 5
+
+// Relationship is a typed edge from one contact to another.
+type Relationship struct {
+	ID            int64     `json:"id"`
+	FromID        int64     `json:"fromId"`
+	ToID          int64     `json:"toId"`
+	Type          string    `json:"type"`
+	Bidirectional bool      `json:"bidirectional"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type RelationshipInput struct {
+	ToID          int64  `json:"toId"`
+	Type          string `json:"type"`
+	Bidirectional bool   `json:"bidirectional"`
+}
+
+// RelatedContact is a relationship as seen from one contact: Type is already
+// inverted for incoming one-way edges.
+type RelatedContact struct {
+	RelationshipID int64  `json:"relationshipId"`
+	ContactID      int64  `json:"contactId"`
+	Type           string `json:"type"`
+	Direction      string `json:"direction"` // "out", "in" or "both"
+}
+
+type NetworkNode struct {
+	Contact Contact `json:"contact"`
+	Depth   int     `json:"depth"`
+}
+
+type ContactNetwork struct {
+	RootID int64          `json:"rootId"`
+	Depth  int            `json:"depth"`
+	Nodes  []NetworkNode  `json:"nodes"`
+	Edges  []Relationship `json:"edges"`
+}
+
+const contactRelationshipsDDL = `
+CREATE TABLE IF NOT EXISTS contact_relationships (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  from_id       BIGINT NOT NULL,
+  to_id         BIGINT NOT NULL,
+  type          VARCHAR(32) NOT NULL,
+  bidirectional BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_edge (from_id, to_id, type),
+  INDEX idx_to (to_id),
+  FOREIGN KEY (from_id) REFERENCES contacts(id) ON DELETE CASCADE,
+  FOREIGN KEY (to_id) REFERENCES contacts(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+func createRelationship(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in RelationshipInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, ok := relationshipTypes[in.Type]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("invalid type %q: must be one of %s", in.Type, strings.Join(relationshipTypeNames(), ", ")))
+		return
+	}
+	if in.ToID <= 0 {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("toId is required"))
+		return
+	}
+	if in.ToID == id {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("a contact cannot be related to itself"))
+		return
+	}
+
+	var n int
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM contacts WHERE id IN (?, ?)`, id, in.ToID).Scan(&n); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if n != 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d or %d not found", id, in.ToID))
+		return
+	}
+	// A bidirectional edge already covers the reverse direction.
+	var exists bool
+	if err := db.QueryRowContext(ctx, `
+SELECT EXISTS (
+  SELECT 1 FROM contact_relationships
+  WHERE from_id = ? AND to_id = ? AND type = ? AND (bidirectional OR ?))`,
+		in.ToID, id, in.Type, in.Bidirectional).Scan(&exists); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusConflict, fmt.Errorf("relationship already exists"))
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	relID, err := insertID(ctx, db, `
+INSERT INTO contact_relationships (from_id, to_id, type, bidirectional, created_at)
+VALUES (?, ?, ?, ?, ?)`, id, in.ToID, in.Type, in.Bidirectional, now)
+	if err != nil {
+		if isDuplicateErr(err) {
+			writeError(w, http.StatusConflict, fmt.Errorf("relationship already exists"))
+			return
+		}
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Relationship{
+		ID:            relID,
+		FromID:        id,
+		ToID:          in.ToID,
+		Type:          in.Type,
+		Bidirectional: in.Bidirectional,
+		CreatedAt:     now,
+	})
+}
+
+// listRelationships returns the direct relationships of a contact in both directions.
+func listRelationships(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	edges, err := loadRelationships(ctx, db, []int64{id})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if len(edges) == 0 {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE id = ?)`, id).Scan(&exists); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": relatedContacts(id, edges)})
+}
+
+// relatedContacts views edges from contact id's side.
+func relatedContacts(id int64, edges []Relationship) []RelatedContact {
+	items := make([]RelatedContact, 0, len(edges))
+	for _, e := range edges {
+		rc := RelatedContact{RelationshipID: e.ID, Type: e.Type}
+		switch {
+		case e.Bidirectional:
+			rc.Direction = "both"
+			rc.ContactID = e.ToID
+			if e.ToID == id {
+				rc.ContactID = e.FromID
+			}
+		case e.FromID == id:
+			rc.Direction = "out"
+			rc.ContactID = e.ToID
+		default:
+			rc.Direction = "in"
+			rc.ContactID = e.FromID
+			rc.Type = relationshipTypes[e.Type]
+		}
+		items = append(items, rc)
+	}
+	return items
+}
+
+// deleteRelationship removes an edge that starts or ends at {id}.
+func deleteRelationship(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	relID, err := parseIDParam(chi.URLParam(r, "relId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `
+DELETE FROM contact_relationships
+WHERE id = ? AND (from_id = ? OR to_id = ?)`, relID, id, id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("relationship %d not found", relID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getContactNetwork walks relationships breadth-first from {id} up to ?depth
+// hops (default 1, max 5), optionally restricted to ?types=a,b.
+func getContactNetwork(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	depth := parseIntDefault(r.URL.Query().Get("depth"), 1)
+	if depth < 1 || depth > maxNetworkDepth {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("depth must be between 1 and %d", maxNetworkDepth))
+		return
+	}
+	var types map[string]bool
+	if s := r.URL.Query().Get("types"); s != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(s, ",") {
+			t = strings.TrimSpace(t)
+			if _, ok := relationshipTypes[t]; !ok {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid type %q", t))
+				return
+			}
+			types[t] = true
+		}
+	}
+
+	levels := map[int64]int{id: 0}
+	seenEdges := make(map[int64]bool)
+	edges := []Relationship{}
+	frontier := []int64{id}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		batch, err := loadRelationships(ctx, db, frontier)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		var next []int64
+		for _, e := range batch {
+			if seenEdges[e.ID] || (types != nil && !types[e.Type]) {
+				continue
+			}
+			seenEdges[e.ID] = true
+			edges = append(edges, e)
+			for _, other := range []int64{e.FromID, e.ToID} {
+				if _, ok := levels[other]; !ok {
+					levels[other] = d
+					next = append(next, other)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	ids := make([]int64, 0, len(levels))
+	for cid := range levels {
+		ids = append(ids, cid)
+	}
+	contacts, err := loadContactsByID(ctx, ids)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if _, ok := contacts[id]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+		return
+	}
+	nodes := make([]NetworkNode, 0, len(contacts))
+	for d := 0; d <= depth; d++ {
+		for _, cid := range ids {
+			if levels[cid] == d {
+				if c, ok := contacts[cid]; ok {
+					nodes = append(nodes, NetworkNode{Contact: c, Depth: d})
+				}
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, renderNetwork(r, ContactNetwork{RootID: id, Depth: depth, Nodes: nodes, Edges: edges}))
+}
+
+// loadRelationships returns every edge touching any of ids.
+func loadRelationships(ctx context.Context, q queryer, ids []int64) ([]Relationship, error) {
+	in, args := inClause(ids)
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := q.QueryContext(ctx, `
+SELECT id, from_id, to_id, type, bidirectional, created_at
+FROM contact_relationships
+WHERE from_id IN (`+in+`) OR to_id IN (`+in+`)
+ORDER BY id`, append(args, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Relationship
+	for rows.Next() {
+		var e Relationship
+		if err := rows.Scan(&e.ID, &e.FromID, &e.ToID, &e.Type, &e.Bidirectional, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func loadContactsByID(ctx context.Context, ids []int64) (map[int64]Contact, error) {
+	out := make(map[int64]Contact, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	in, args := inClause(ids)
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := stmts.query(ctx, db, `
+SELECT `+contactColumns+`
+FROM contacts WHERE id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanContacts(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range items {
+		out[c.ID] = c
+	}
+	return out, nil
+}
+
+// inClause builds "?, ?, ?" and the matching args for an IN (...) list.
+func inClause(ids []int64) (string, []any) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+func relationshipTypeNames() []string {
+	return []string{"reports_to", "assistant_of", "referred_by", "colleague_of"}
+}
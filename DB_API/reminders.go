@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Supported recurrences. A recurring reminder is never completed; completing
+// it moves dueAt to the next occurrence after now.
+var reminderRecurrences = []string{"daily", "weekly", "biweekly", "monthly", "yearly"}
+
+// Reminder is a follow-up scheduled against a contact.
+type Reminder struct {
+	ID          int64      `json:"id"`
+	ContactID   int64      `json:"contactId"`
+	Title       string     `json:"title"`
+	Notes       *string    `json:"notes,omitempty"`
+	DueAt       time.Time  `json:"dueAt"`
+	Recurrence  *string    `json:"recurrence,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	FiredAt     *time.Time `json:"firedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// ReminderInput sets the due date either absolutely (dueAt) or relative to
+// now (dueIn, e.g. "2w", "14d", "36h").
+type ReminderInput struct {
+	Title      string     `json:"title"`
+	Notes      *string    `json:"notes"`
+	DueAt      *time.Time `json:"dueAt"`
+	DueIn      string     `json:"dueIn"`
+	Recurrence *string    `json:"recurrence"`
+}
+
+type PartialReminder struct {
+	Title      *string    `json:"title"`
+	Notes      *string    `json:"notes"`
+	DueAt      *time.Time `json:"dueAt"`
+	DueIn      *string    `json:"dueIn"`
+	Recurrence *string    `json:"recurrence"`
+}
+
+// DueReminder is what the scheduler hands to a Notifier.
+type DueReminder struct {
+	Reminder
+	ContactName  string `json:"contactName"`
+	ContactEmail string `json:"contactEmail"`
+}
+
+const contactRemindersDDL = `
+CREATE TABLE IF NOT EXISTS contact_reminders (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  contact_id   BIGINT NOT NULL,
+  title        VARCHAR(255) NOT NULL,
+  notes        TEXT,
+  due_at       TIMESTAMP NOT NULL,
+  recurrence   VARCHAR(16),
+  completed_at TIMESTAMP NULL,
+  fired_at     TIMESTAMP NULL,
+  created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_contact (contact_id),
+  INDEX idx_open_due (completed_at, due_at),
+  FOREIGN KEY (contact_id) REFERENCES contacts(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+const reminderColumns = `r.id, r.contact_id, r.title, r.notes, r.due_at, r.recurrence, r.completed_at, r.fired_at, r.created_at, r.updated_at`
+
+func createReminder(w http.ResponseWriter, r *http.Request) {
+	contactID, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in ReminderInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	due, err := resolveDue(in.DueAt, in.DueIn, now)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := validateReminderTitle(in.Title); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := validateRecurrence(in.Recurrence); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	id, err := insertID(ctx, db, `
+INSERT INTO contact_reminders (contact_id, title, notes, due_at, recurrence, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		contactID, in.Title, nullable(in.Notes), due, nullable(in.Recurrence), now, now)
+	if err != nil {
+		if isForeignKeyErr(err) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("contact %d not found", contactID))
+			return
+		}
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Reminder{
+		ID:         id,
+		ContactID:  contactID,
+		Title:      in.Title,
+		Notes:      in.Notes,
+		DueAt:      due,
+		Recurrence: in.Recurrence,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+}
+
+// listContactReminders returns a contact's open reminders by due date;
+// ?includeCompleted=true also returns completed ones.
+func listContactReminders(w http.ResponseWriter, r *http.Request) {
+	contactID, err := parseIDParam(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	where := "r.contact_id = ?"
+	if r.URL.Query().Get("includeCompleted") != "true" {
+		where += " AND r.completed_at IS NULL"
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT `+reminderColumns+`
+FROM contact_reminders r
+WHERE `+where+`
+ORDER BY r.due_at, r.id`, contactID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []Reminder{}
+	for rows.Next() {
+		rem, err := scanReminder(rows)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		items = append(items, rem)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func getReminder(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "reminderId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rem, err := scanReminder(db.QueryRowContext(ctx, `
+SELECT `+reminderColumns+`
+FROM contact_reminders r WHERE r.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("reminder %d not found", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rem)
+}
+
+// patchReminder edits a reminder. Moving dueAt re-arms it for the scheduler.
+func patchReminder(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "reminderId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var in PartialReminder
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	fields := make([]string, 0, 6)
+	args := make([]any, 0, 7)
+	if in.Title != nil {
+		if err := validateReminderTitle(*in.Title); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		fields = append(fields, "title = ?")
+		args = append(args, *in.Title)
+	}
+	if in.Notes != nil {
+		fields = append(fields, "notes = ?")
+		args = append(args, nullable(in.Notes))
+	}
+	if in.DueAt != nil || in.DueIn != nil {
+		dueIn := ""
+		if in.DueIn != nil {
+			dueIn = *in.DueIn
+		}
+		due, err := resolveDue(in.DueAt, dueIn, now)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		fields = append(fields, "due_at = ?", "fired_at = NULL")
+		args = append(args, due)
+	}
+	if in.Recurrence != nil {
+		if *in.Recurrence == "" {
+			in.Recurrence = nil
+		}
+		if err := validateRecurrence(in.Recurrence); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		fields = append(fields, "recurrence = ?")
+		args = append(args, nullable(in.Recurrence))
+	}
+	if len(fields) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no updatable fields provided"))
+		return
+	}
+	fields = append(fields, "updated_at = ?")
+	args = append(args, now, id)
+
+	q := fmt.Sprintf("UPDATE contact_reminders SET %s WHERE id = ?", strings.Join(fields, ", "))
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("reminder %d not found", id))
+		return
+	}
+	getReminder(w, r)
+}
+
+// completeReminder marks a one-off reminder done, or advances a recurring one
+// to its next occurrence after now.
+func completeReminder(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "reminderId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var due time.Time
+	var recurrence sql.NullString
+	var completed sql.NullTime
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	err = db.QueryRowContext(ctx, `SELECT due_at, recurrence, completed_at FROM contact_reminders WHERE id = ?`, id).
+		Scan(&due, &recurrence, &completed)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("reminder %d not found", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if completed.Valid {
+		writeError(w, http.StatusConflict, fmt.Errorf("reminder %d is already completed", id))
+		return
+	}
+
+	// Only update the occurrence read above, so that of two concurrent calls
+	// one completes it and the other gets 409 instead of advancing it twice.
+	now := time.Now().UTC().Truncate(time.Second)
+	var res sql.Result
+	if recurrence.Valid {
+		res, err = db.ExecContext(ctx, `
+UPDATE contact_reminders SET due_at = ?, fired_at = NULL, updated_at = ?
+WHERE id = ? AND due_at = ? AND completed_at IS NULL`,
+			nextOccurrence(due, recurrence.String, now), now, id, due)
+	} else {
+		res, err = db.ExecContext(ctx, `
+UPDATE contact_reminders SET completed_at = ?, updated_at = ?
+WHERE id = ? AND due_at = ? AND completed_at IS NULL`, now, now, id, due)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusConflict, fmt.Errorf("reminder %d was changed by another request", id))
+		return
+	}
+	getReminder(w, r)
+}
+
+func deleteReminder(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "reminderId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `DELETE FROM contact_reminders WHERE id = ?`, id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("reminder %d not found", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDueReminders returns open reminders due at or before ?before (RFC 3339,
+// default now), oldest first.
+func listDueReminders(w http.ResponseWriter, r *http.Request) {
+	before := time.Now().UTC()
+	if s := r.URL.Query().Get("before"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid before: %q", s))
+			return
+		}
+		before = t.UTC()
+	}
+	limit := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("limit"), 0))
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	items, err := loadDueReminders(ctx, before, false, limit)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"before": before, "items": items})
+}
+
+// Scheduler
+
+// runReminderScheduler polls for due reminders every interval and fires each
+// one once per due date through n. It returns when ctx is cancelled.
+func runReminderScheduler(ctx context.Context, interval time.Duration, n Notifier) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		fireDueReminders(ctx, n)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func fireDueReminders(ctx context.Context, n Notifier) {
+	due, err := loadDueReminders(ctx, time.Now().UTC(), true, 100)
+	if err != nil {
+		slog.Error("reminders: load due", "err", err)
+		return
+	}
+	for _, rem := range due {
+		// Claim the reminder first so that concurrent schedulers (e.g. several
+		// replicas) do not notify twice for the same due date.
+		now := time.Now().UTC().Truncate(time.Second)
+		claimed, err := claimReminder(ctx, rem, now)
+		if err != nil {
+			slog.Error("reminders: claim", "reminder_id", rem.ID, "err", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		rem.FiredAt = &now
+		if err := n.Notify(ctx, rem); err != nil {
+			slog.Error("reminders: notify", "reminder_id", rem.ID, "err", err)
+			remindersNotified.WithLabelValues("failed").Inc()
+			// Release the claim so the next tick retries.
+			if err := releaseReminder(ctx, rem.ID); err != nil {
+				slog.Error("reminders: release", "reminder_id", rem.ID, "err", err)
+			}
+			continue
+		}
+		remindersNotified.WithLabelValues("sent").Inc()
+	}
+}
+
+// claimReminder marks rem fired at now unless another scheduler got there
+// first.
+func claimReminder(ctx context.Context, rem DueReminder, now time.Time) (bool, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	res, err := db.ExecContext(ctx, `
+UPDATE contact_reminders SET fired_at = ? WHERE id = ? AND fired_at IS NULL AND due_at = ?`,
+		now, rem.ID, rem.DueAt)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+func releaseReminder(ctx context.Context, id int64) error {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	_, err := db.ExecContext(ctx, `UPDATE contact_reminders SET fired_at = NULL WHERE id = ?`, id)
+	return err
+}
+
+// loadDueReminders returns open reminders due at or before t. unfiredOnly
+// skips those the scheduler already notified for.
+func loadDueReminders(ctx context.Context, t time.Time, unfiredOnly bool, limit int) ([]DueReminder, error) {
+	where := "r.completed_at IS NULL AND r.due_at <= ?"
+	if unfiredOnly {
+		where += " AND r.fired_at IS NULL"
+	}
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT `+reminderColumns+`, c.first_name, c.last_name, c.email
+FROM contact_reminders r
+JOIN contacts c ON c.id = r.contact_id
+WHERE `+where+`
+ORDER BY r.due_at, r.id
+LIMIT ?`, t, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []DueReminder{}
+	for rows.Next() {
+		var d DueReminder
+		var first, last string
+		rem := &d.Reminder
+		var notes, recurrence sql.NullString
+		var completed, fired sql.NullTime
+		if err := rows.Scan(&rem.ID, &rem.ContactID, &rem.Title, &notes, &rem.DueAt, &recurrence, &completed, &fired, &rem.CreatedAt, &rem.UpdatedAt,
+			&first, &last, &d.ContactEmail); err != nil {
+			return nil, err
+		}
+		fillReminderNulls(rem, notes, recurrence, completed, fired)
+		d.ContactName = first + " " + last
+		items = append(items, d)
+	}
+	return items, rows.Err()
+}
+
+// Helpers
+
+func scanReminder(row rowScanner) (Reminder, error) {
+	var rem Reminder
+	var notes, recurrence sql.NullString
+	var completed, fired sql.NullTime
+	if err := row.Scan(&rem.ID, &rem.ContactID, &rem.Title, &notes, &rem.DueAt, &recurrence, &completed, &fired, &rem.CreatedAt, &rem.UpdatedAt); err != nil {
+		return rem, err
+	}
+	fillReminderNulls(&rem, notes, recurrence, completed, fired)
+	return rem, nil
+}
+
+func fillReminderNulls(rem *Reminder, notes, recurrence sql.NullString, completed, fired sql.NullTime) {
+	if notes.Valid {
+		rem.Notes = &notes.String
+	}
+	if recurrence.Valid {
+		rem.Recurrence = &recurrence.String
+	}
+	if completed.Valid {
+		rem.CompletedAt = &completed.Time
+	}
+	if fired.Valid {
+		rem.FiredAt = &fired.Time
+	}
+}
+
+func resolveDue(dueAt *time.Time, dueIn string, now time.Time) (time.Time, error) {
+	switch {
+	case dueAt != nil && dueIn != "":
+		return time.Time{}, fmt.Errorf("set either dueAt or dueIn, not both")
+	case dueAt != nil:
+		return dueAt.UTC().Truncate(time.Second), nil
+	case dueIn != "":
+		d, err := parseRelativeDuration(dueIn)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("dueAt or dueIn is required")
+}
+
+// parseRelativeDuration accepts Go durations ("36h") plus whole days ("14d")
+// and weeks ("2w").
+func parseRelativeDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid dueIn: %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid dueIn: %q", s)
+	}
+	return d, nil
+}
+
+// validateReminderTitle keeps titles to one line, since the email notifier
+// puts them in the Subject header, and within the title column.
+func validateReminderTitle(title string) error {
+	switch {
+	case strings.TrimSpace(title) == "":
+		return fmt.Errorf("title is required")
+	case strings.ContainsAny(title, "\r\n"):
+		return fmt.Errorf("title must not contain line breaks")
+	case utf8.RuneCountInString(title) > 255:
+		return fmt.Errorf("title must be at most 255 characters")
+	}
+	return nil
+}
+
+func validateRecurrence(rec *string) error {
+	if rec == nil || slices.Contains(reminderRecurrences, *rec) {
+		return nil
+	}
+	return fmt.Errorf("invalid recurrence %q: must be one of %s", *rec, strings.Join(reminderRecurrences, ", "))
+}
+
+// nextOccurrence steps due forward by the recurrence until it is after now.
+func nextOccurrence(due time.Time, recurrence string, now time.Time) time.Time {
+	step := func(t time.Time) time.Time {
+		switch recurrence {
+		case "daily":
+			return t.AddDate(0, 0, 1)
+		case "weekly":
+			return t.AddDate(0, 0, 7)
+		case "biweekly":
+			return t.AddDate(0, 0, 14)
+		case "monthly":
+			return t.AddDate(0, 1, 0)
+		default: // yearly
+			return t.AddDate(1, 0, 0)
+		}
+	}
+	next := step(due)
+	for !next.After(now) {
+		next = step(next)
+	}
+	return next
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"my-go-api/client"
+)
+
+func TestValidateReminderTitle(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		ok    bool
+	}{
+		{"Call back", true},
+		{"Relancer André", true},
+		{strings.Repeat("é", 255), true},
+		{"", false},
+		{"   ", false},
+		{strings.Repeat("é", 256), false},
+		{"Hi\r\nBcc: everyone@example.com", false},
+		{"Hi\nthere", false},
+	} {
+		if err := validateReminderTitle(tc.title); (err == nil) != tc.ok {
+			t.Errorf("validateReminderTitle(%q) = %v, want ok %v", tc.title, err, tc.ok)
+		}
+	}
+}
+
+// TestCompleteReminderOnce completes the same one-off reminder from several
+// requests at once: exactly one may succeed.
+func TestCompleteReminderOnce(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		var rem Reminder
+		if status := do(t, h, http.MethodPost, fmt.Sprintf("/contacts/%d/reminders", id), client.ReminderInput{Title: "Follow up", DueIn: ptr("2w")}, &rem); status != http.StatusCreated {
+			t.Fatalf("create: status %d", status)
+		}
+		var wg sync.WaitGroup
+		statuses := make(chan int, 8)
+		for range cap(statuses) {
+			wg.Go(func() {
+				statuses <- do(t, h, http.MethodPost, fmt.Sprintf("/reminders/%d/complete", rem.ID), nil, nil)
+			})
+		}
+		wg.Wait()
+		close(statuses)
+		counts := make(map[int]int)
+		for status := range statuses {
+			counts[status]++
+		}
+		if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != cap(statuses)-1 {
+			t.Errorf("statuses %v, want one 200 and the rest 409", counts)
+		}
+	})
+}
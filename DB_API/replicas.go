@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Read replicas. db.replicas (DB_REPLICA_DSNS, comma-separated) lists DSNs
+// for replicas of the primary in db.dsn, in the same driver's format.
+// Contact gets and lists, over REST, GraphQL and gRPC, read from them in
+// turn; everything else uses the primary. Each replica is pinged every db.replicaCheckInterval and
+// leaves the rotation while that fails. A read that cannot reach its replica
+// takes it out too and is retried on the primary, which serves all reads
+// while no replica is up.
+//
+// Replication is asynchronous, so a read right after a write may not see it.
+// A response to a request that changed a contact carries a Consistency-Token
+// header (consistency-token metadata over gRPC); a read sending it back the
+// same way within db.readYourWrites (DB_READ_YOUR_WRITES) of the change is
+// served by the primary.
+const (
+	consistencyHeader   = "Consistency-Token"
+	consistencyMetadata = "consistency-token"
+	// consistencySkew is how far in the future a token may be, for clocks
+	// between instances that disagree a little. Later ones are ignored, so
+	// that a made-up token cannot pin a client to the primary.
+	consistencySkew = time.Second
+)
+
+type replica struct {
+	name string // labels logs and metrics
+	db   *sql.DB
+	up   atomic.Bool
+}
+
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+var replicas = &replicaSet{}
+
+var replicaUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "db_replica_up",
+	Help: "Whether a read replica is in rotation (1) or not (0).",
+}, []string{"replica"})
+
+func init() {
+	metricsRegistry.MustRegister(replicaUp)
+}
+
+// open opens a pool for each of c.Replicas. They start out of rotation
+// until run has checked them.
+func (s *replicaSet) open(c DBConfig) error {
+	for i, dsn := range c.Replicas {
+		rc := c
+		rc.DSN = dsn
+		pool, err := openDB(rc)
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		r := &replica{name: fmt.Sprintf("%s-replica%d", rc.name(), i+1), db: pool}
+		registerDBMetrics(pool, r.name)
+		replicaUp.WithLabelValues(r.name).Set(0)
+		s.replicas = append(s.replicas, r)
+	}
+	return nil
+}
+
+// pools returns the replicas' pools.
+func (s *replicaSet) pools() []*sql.DB {
+	pools := make([]*sql.DB, len(s.replicas))
+	for i, r := range s.replicas {
+		pools[i] = r.db
+	}
+	return pools
+}
+
+func (s *replicaSet) close() {
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			slog.Error("close db replica", "replica", r.name, "err", err)
+		}
+	}
+}
+
+// run pings every replica each interval until ctx is done, putting the ones
+// that answer in rotation and taking the others out.
+func (s *replicaSet) run(ctx context.Context, interval time.Duration) {
+	if len(s.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range s.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, cfg().Health.CheckTimeout)
+			err := r.db.PingContext(pingCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			r.setUp(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setUp puts r in rotation if err is nil and takes it out otherwise.
+func (r *replica) setUp(err error) {
+	up := err == nil
+	if up {
+		replicaUp.WithLabelValues(r.name).Set(1)
+	} else {
+		replicaUp.WithLabelValues(r.name).Set(0)
+	}
+	if r.up.Swap(up) == up {
+		return
+	}
+	if up {
+		slog.Info("db replica up", "replica", r.name)
+	} else {
+		slog.Warn("db replica down, reading from the primary", "replica", r.name, "err", err)
+	}
+}
+
+// pick returns the next replica in rotation, or nil if none is up.
+func (s *replicaSet) pick() *replica {
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := range n {
+		if r := s.replicas[(start+i)%n]; r.up.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// readReplica runs the read fn on a replica, or on the primary if none is up
+// or the request ctx belongs to carries a Consistency-Token from a recent
+// write. fn must do all its reading through q. If the replica cannot be
+// reached it is taken out of rotation and fn retried on the primary.
+func readReplica(ctx context.Context, fn func(q queryer) error) error {
+	var rep *replica
+	if !recentlyWrote(consistencyToken(ctx), time.Now()) {
+		rep = replicas.pick()
+	}
+	if rep == nil {
+		return fn(db)
+	}
+	err := fn(rep.db)
+	if err == nil || !isUnavailableErr(err) || ctx.Err() != nil {
+		return err
+	}
+	rep.setUp(err)
+	return fn(db)
+}
+
+// recentlyWrote reports whether token is a Consistency-Token issued within
+// db.readYourWrites of now.
+func recentlyWrote(token string, now time.Time) bool {
+	ms, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now.Sub(time.UnixMilli(ms))
+	return age >= -consistencySkew && age < cfg().DB.ReadYourWrites
+}
+
+// writeStampKey holds the response header that markContactWrite sets the
+// Consistency-Token on.
+type writeStampKey struct{}
+
+// consistencyTokenKey holds the Consistency-Token a request sent.
+type consistencyTokenKey struct{}
+
+// stampWrites lets writes made while serving a request issue it a
+// Consistency-Token, and its reads see the one it sent.
+func stampWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), writeStampKey{}, w.Header())
+		ctx = context.WithValue(ctx, consistencyTokenKey{}, r.Header.Get(consistencyHeader))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// consistencyToken returns the Consistency-Token sent with the HTTP or gRPC
+// request ctx belongs to.
+func consistencyToken(ctx context.Context) string {
+	if token, ok := ctx.Value(consistencyTokenKey{}).(string); ok {
+		return token
+	}
+	if v := metadata.ValueFromIncomingContext(ctx, consistencyMetadata); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// markContactWrite issues the Consistency-Token for a contact change made
+// with ctx, as a response header over HTTP or header metadata over gRPC.
+func markContactWrite(ctx context.Context) {
+	token := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if h, ok := ctx.Value(writeStampKey{}).(http.Header); ok {
+		h.Set(consistencyHeader, token)
+		return
+	}
+	if grpc.ServerTransportStreamFromContext(ctx) != nil {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(consistencyMetadata, token))
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"my-go-api/contactspb"
+)
+
+func TestRecentlyWrote(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DB.ReadYourWrites = 5 * time.Second })
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	token := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).UnixMilli(), 10) }
+	for _, tt := range []struct {
+		name, token string
+		want        bool
+	}{
+		{"just now", token(0), true},
+		{"within the window", token(-4 * time.Second), true},
+		{"after the window", token(-5 * time.Second), false},
+		{"slightly ahead", token(500 * time.Millisecond), true},
+		{"far future", token(time.Hour), false},
+		{"none", "", false},
+		{"garbage", "soon", false},
+	} {
+		if got := recentlyWrote(tt.token, now); got != tt.want {
+			t.Errorf("%s: recentlyWrote(%q) = %v, want %v", tt.name, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	if got := consistencyToken(context.Background()); got != "" {
+		t.Errorf("no request: %q", got)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(consistencyMetadata, "42"))
+	if got := consistencyToken(ctx); got != "42" {
+		t.Errorf("gRPC metadata: %q, want 42", got)
+	}
+	ctx = context.WithValue(ctx, consistencyTokenKey{}, "")
+	if got := consistencyToken(ctx); got != "" {
+		t.Errorf("HTTP request without the header: %q", got)
+	}
+}
+
+// downDB returns a pool for the current backend that cannot connect.
+func downDB(t *testing.T) *sql.DB {
+	t.Helper()
+	c := DBConfig{Driver: "mysql", DSN: "root@tcp(127.0.0.1:1)/contactsdb?timeout=1s"}
+	if _, ok := dialect.(postgresDialect); ok {
+		c = DBConfig{Driver: "postgres", DSN: "postgres://postgres@127.0.0.1:1/contactsdb?connect_timeout=1"}
+	}
+	pool, err := openDB(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+// TestReadReplica points a replica at the test database and the primary at
+// nothing, so a read only succeeds if it is served by the replica.
+func TestReadReplica(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		rpc := grpcTestClient(t)
+		id := createTestContact(t, h, "Ada", "ada@example.com")
+		primary := db
+		rep := &replica{name: "test-replica", db: primary}
+		rep.up.Store(true)
+		prev := replicas
+		replicas = &replicaSet{replicas: []*replica{rep}}
+		db = downDB(t)
+		t.Cleanup(func() { replicas, db = prev, primary })
+
+		ctx := context.Background()
+		path := fmt.Sprintf("/contacts/%d", id)
+		gql := map[string]any{"query": fmt.Sprintf(`{ contact(id: "%d") { email } contacts { totalCount } }`, id)}
+		var gqlResp struct {
+			Errors []any
+			Error  string
+		}
+		for name, read := range map[string]func() error{
+			"REST get":  func() error { return statusError(do(t, h, http.MethodGet, path, nil, nil)) },
+			"REST list": func() error { return statusError(do(t, h, http.MethodGet, "/contacts", nil, nil)) },
+			"GraphQL": func() error {
+				if status := do(t, h, http.MethodPost, "/graphql", gql, &gqlResp); status != http.StatusOK || len(gqlResp.Errors) > 0 {
+					return fmt.Errorf("status %d %s, errors %v", status, gqlResp.Error, gqlResp.Errors)
+				}
+				return nil
+			},
+			"gRPC get": func() error {
+				_, err := rpc.GetContact(ctx, &contactspb.GetContactRequest{Id: id})
+				return err
+			},
+			"gRPC list": func() error {
+				stream, err := rpc.ListContacts(ctx, &contactspb.ListContactsRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+		} {
+			if err := read(); err != nil {
+				t.Errorf("%s with the primary down: %v", name, err)
+			}
+		}
+		if !rep.up.Load() {
+			t.Error("the primary being down took the replica out of rotation")
+		}
+
+		// A fresh token sends the read to the primary; one from the future does not.
+		for _, tt := range []struct {
+			token string
+			want  int
+		}{
+			{strconv.FormatInt(time.Now().UnixMilli(), 10), http.StatusServiceUnavailable},
+			{strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10), http.StatusOK},
+		} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Header.Set(consistencyHeader, tt.token)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("Consistency-Token %s: status %d, want %d", tt.token, w.Code, tt.want)
+			}
+		}
+
+		// A replica that cannot be reached leaves the rotation and the read
+		// is retried on the primary.
+		db, rep.db = primary, db
+		if err := statusError(do(t, h, http.MethodGet, path, nil, nil)); err != nil {
+			t.Errorf("replica down: %v", err)
+		}
+		if rep.up.Load() {
+			t.Error("unreachable replica still in rotation")
+		}
+	})
+}
+
+func statusError(status int) error {
+	if status != http.StatusOK {
+		return fmt.Errorf("status %d", status)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The query layer for contacts: the column list every contact SELECT uses,
+// the scanner for it, and a cache of prepared statements. The statements
+// below are prepared once at startup, which also catches SQL that no longer
+// matches the schema before the server takes traffic; database/sql then
+// prepares each one on a connection the first time it runs there and reuses
+// it, so MySQL parses it once per connection instead of once per call.
+// Queries built at run time (filters, sort order, IN lists) are cached the
+// same way up to db.stmtCacheSize (DB_STMT_CACHE_SIZE) distinct texts, and
+// run unprepared after that. Each read replica's pool (see replicas.go) has
+// a cache of its own, filled as statements first run there.
+
+// contactColumns is what scanContact reads, in order.
+const contactColumns = `id, first_name, last_name, company, email, phone, created_at, updated_at`
+
+const (
+	sqlContactByID = `
+SELECT ` + contactColumns + `
+FROM contacts WHERE id = ?`
+	sqlLockContact = `
+SELECT ` + contactColumns + `
+FROM contacts WHERE id = ? FOR UPDATE`
+	sqlAllContacts = `
+SELECT ` + contactColumns + `
+FROM contacts
+ORDER BY id`
+	sqlInsertContact = `
+INSERT INTO contacts (first_name, last_name, company, email, phone, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	sqlReplaceContact = `
+UPDATE contacts
+SET first_name = ?, last_name = ?, company = ?, email = ?, phone = ?, updated_at = ?
+WHERE id = ?`
+	sqlDeleteContact = `DELETE FROM contacts WHERE id = ?`
+
+	sqlCustomFields = `
+SELECT id, name, label, type, required, rules, created_at
+FROM custom_fields
+ORDER BY id`
+	sqlContactFieldValues = `SELECT field_id, value FROM contact_field_values WHERE contact_id = ?`
+)
+
+// startupStatements are the statements above as db's dialect runs them.
+func startupStatements() []string {
+	insert, _ := dialect.returningID(sqlInsertContact)
+	return []string{
+		sqlContactByID, sqlLockContact, sqlAllContacts,
+		insert, sqlReplaceContact, sqlDeleteContact,
+		sqlCustomFields, sqlContactFieldValues,
+	}
+}
+
+// stmts is the statement cache for db, set up by prepareStatements.
+var stmts *stmtCache
+
+var stmtCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "db_statement_cache_lookups_total",
+	Help: "Prepared statement lookups by result: hit, prepared, or unprepared when the cache was full or preparing failed.",
+}, []string{"result"})
+
+func init() {
+	metricsRegistry.MustRegister(stmtCacheLookups)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	queryer
+	execer
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanContact reads one row of contactColumns. NULL company and phone are
+// left nil.
+func scanContact(row rowScanner) (Contact, error) {
+	var c Contact
+	var company, phone sql.NullString
+	if err := row.Scan(&c.ID, &c.FirstName, &c.LastName, &company, &c.Email, &phone, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return c, err
+	}
+	c.Company = stringPtr(company)
+	c.Phone = stringPtr(phone)
+	return c, nil
+}
+
+// scanContacts reads every row of contactColumns and closes rows.
+func scanContacts(rows *sql.Rows) ([]Contact, error) {
+	defer rows.Close()
+	var items []Contact
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// stringPtr is the reverse of nullable.
+func stringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// stmtCache holds the prepared statements for one pool, keyed by SQL text.
+type stmtCache struct {
+	db    *sql.DB
+	limit int
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+
+	// replicas are the caches for the replicas' pools, set up with db's.
+	replicas map[*sql.DB]*stmtCache
+}
+
+func newStmtCache(db *sql.DB, limit int) *stmtCache {
+	return &stmtCache{db: db, limit: limit, stmts: make(map[string]*sql.Stmt)}
+}
+
+// prepareStatements prepares startupStatements on db and installs the cache,
+// with an empty one for each of replicaPools: a replica may be down at
+// startup, so its statements are prepared when they first run.
+func prepareStatements(ctx context.Context, db *sql.DB, replicaPools []*sql.DB, limit int) error {
+	startup := startupStatements()
+	c := newStmtCache(db, len(startup)+limit)
+	c.replicas = make(map[*sql.DB]*stmtCache, len(replicaPools))
+	for _, pool := range replicaPools {
+		c.replicas[pool] = newStmtCache(pool, len(startup)+limit)
+	}
+	for _, query := range startup {
+		s, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("prepare %q: %w", query, err)
+		}
+		c.stmts[query] = s
+	}
+	stmts = c
+	return nil
+}
+
+// get returns the prepared statement for query, preparing and caching it
+// the first time unless cachedOnly is set. It returns nil once the cache is
+// full, or if query could not be prepared; the caller then runs it
+// unprepared, which reports the error if there is one.
+func (c *stmtCache) get(ctx context.Context, query string, cachedOnly bool) *sql.Stmt {
+	c.mu.RLock()
+	s := c.stmts[query]
+	full := len(c.stmts) >= c.limit
+	c.mu.RUnlock()
+	if s != nil {
+		stmtCacheLookups.WithLabelValues("hit").Inc()
+		return s
+	}
+	if full || cachedOnly {
+		stmtCacheLookups.WithLabelValues("unprepared").Inc()
+		return nil
+	}
+
+	s, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		stmtCacheLookups.WithLabelValues("unprepared").Inc()
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev := c.stmts[query]; prev != nil {
+		s.Close() // prepared concurrently
+		stmtCacheLookups.WithLabelValues("hit").Inc()
+		return prev
+	}
+	c.stmts[query] = s
+	stmtCacheLookups.WithLabelValues("prepared").Inc()
+	return s
+}
+
+// bind returns query's statement for use through q, which is c's pool, a
+// replica's or a transaction on c's, or nil if query is to run unprepared.
+// A transaction only uses statements already cached: preparing a new one
+// takes a second connection from the pool while the transaction holds its
+// own, and with every connection in a transaction that would wait for the
+// timeout.
+func (c *stmtCache) bind(ctx context.Context, q any, query string) *sql.Stmt {
+	switch q := q.(type) {
+	case *sql.DB:
+		if q == c.db {
+			return c.get(ctx, query, false)
+		}
+		if r := c.replicas[q]; r != nil {
+			return r.get(ctx, query, false)
+		}
+	case *sql.Tx:
+		if s := c.get(ctx, query, true); s != nil {
+			return q.StmtContext(ctx, s)
+		}
+	}
+	return nil
+}
+
+func (c *stmtCache) query(ctx context.Context, q queryer, query string, args ...any) (*sql.Rows, error) {
+	if s := c.bind(ctx, q, query); s != nil {
+		return s.QueryContext(ctx, args...)
+	}
+	return q.QueryContext(ctx, query, args...)
+}
+
+func (c *stmtCache) queryRow(ctx context.Context, q queryer, query string, args ...any) *sql.Row {
+	if s := c.bind(ctx, q, query); s != nil {
+		return s.QueryRowContext(ctx, args...)
+	}
+	return q.QueryRowContext(ctx, query, args...)
+}
+
+func (c *stmtCache) exec(ctx context.Context, q execer, query string, args ...any) (sql.Result, error) {
+	if s := c.bind(ctx, q, query); s != nil {
+		return s.ExecContext(ctx, args...)
+	}
+	return q.ExecContext(ctx, query, args...)
+}
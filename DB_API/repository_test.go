@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// benchContacts is how many contacts the benchmarks seed.
+const benchContacts = 200
+
+// benchmarkStatements runs fn per configured backend, against benchContacts
+// seeded contacts, once through the statement cache and once with every
+// query unprepared.
+func benchmarkStatements(b *testing.B, fn func(b *testing.B, ids []int64)) {
+	ran := false
+	for _, be := range testBackends {
+		dsn := os.Getenv(be.env)
+		if dsn == "" {
+			continue
+		}
+		ran = true
+		b.Run(be.driver, func(b *testing.B) {
+			useTestDB(b, DBConfig{Driver: be.driver, DSN: dsn})
+			ids := make([]int64, benchContacts)
+			for i := range ids {
+				c, err := insertContact(context.Background(), ContactInput{FirstName: "Bench", LastName: fmt.Sprint(i), Email: fmt.Sprintf("bench%d@example.com", i)})
+				if err != nil {
+					b.Fatal(err)
+				}
+				ids[i] = c.ID
+			}
+			b.Run("stmtCache", func(b *testing.B) { fn(b, ids) })
+			b.Run("unprepared", func(b *testing.B) {
+				cached := stmts
+				stmts = newStmtCache(db, 0)
+				b.Cleanup(func() { stmts = cached })
+				fn(b, ids)
+			})
+		})
+	}
+	if !ran {
+		b.Skip("set TEST_MYSQL_DSN or TEST_POSTGRES_DSN to run against a database")
+	}
+}
+
+func BenchmarkGetContact(b *testing.B) {
+	benchmarkStatements(b, func(b *testing.B, ids []int64) {
+		ctx := context.Background()
+		i := 0
+		for b.Loop() {
+			if _, err := loadContact(ctx, db, ids[i%len(ids)]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkListContacts(b *testing.B) {
+	benchmarkStatements(b, func(b *testing.B, _ []int64) {
+		ctx := context.Background()
+		q := contactQuery{Search: "bench", SortColumn: "last_name"}
+		for b.Loop() {
+			items, err := queryContacts(ctx, db, q, 50, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(items) != 50 {
+				b.Fatalf("listed %d contacts, want 50", len(items))
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ready is true while this instance should receive traffic: from the moment
+// it listens until shutdown begins. GET /readyz fails while it is false.
+var ready atomic.Bool
+
+// shutdownCtx is cancelled when graceful shutdown begins. Server.Shutdown
+// waits for in-flight requests but neither interrupts SSE responses nor
+// tracks hijacked WebSocket connections, so those watch this instead.
+var shutdownCtx, beginShutdown = context.WithCancel(context.Background())
+
+func newHTTPServer(h http.Handler, c HTTPConfig) *http.Server {
+	srv := &http.Server{
+		Addr:              c.Addr,
+		Handler:           h,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+	srv.RegisterOnShutdown(beginShutdown)
+	return srv
+}
+
+// endOnShutdown marks a streaming route. It lifts http.requestTimeout,
+// keeping only the client's own cancellation, and cancels the request
+// context when shutdown begins, since a stream would otherwise run until the
+// drain deadline.
+func endOnShutdown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent := r.Context()
+		untimed, lifted := parent.Value(untimedCtxKey{}).(context.Context)
+		if lifted {
+			parent = context.WithoutCancel(parent)
+		}
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+		if lifted {
+			stop := context.AfterFunc(untimed, cancel)
+			defer stop()
+		}
+		stop := context.AfterFunc(shutdownCtx, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// untimedCtxKey holds the request context as it was before timeoutRequests
+// added its deadline, for endOnShutdown.
+type untimedCtxKey struct{}
+
+// timeoutRequests gives handlers http.requestTimeout to finish. Storage
+// calls made with the request context fail once it passes, and the handler
+// reports 504; if it returns without writing anything, 504 is sent here.
+func timeoutRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := cfg().HTTP.RequestTimeout
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		ctx = context.WithValue(ctx, untimedCtxKey{}, r.Context())
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+		if ww.Status() == 0 && !isUpgrade(r) && ctx.Err() == context.DeadlineExceeded {
+			writeAPIError(ww, ctx.Err())
+		}
+	})
+}
+
+// isUpgrade reports whether r asks to switch protocols, as a WebSocket
+// handshake does. A handler that accepted it has hijacked the connection,
+// so nothing may be written to w afterwards. Browsers send
+// "Connection: keep-alive, Upgrade", so the header is searched for the token.
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for token := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// waitTimeout waits for wg for up to timeout and reports whether it is done.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
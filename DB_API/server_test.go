@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsUpgrade(t *testing.T) {
+	for _, tt := range []struct {
+		connection []string
+		upgrade    string
+		want       bool
+	}{
+		{[]string{"Upgrade"}, "websocket", true},
+		{[]string{"keep-alive, Upgrade"}, "websocket", true}, // Firefox
+		{[]string{"keep-alive", "upgrade"}, "websocket", true},
+		{[]string{"keep-alive"}, "websocket", false},
+		{[]string{"Upgrade"}, "", false},
+		{nil, "", false},
+		{[]string{"Upgraded"}, "websocket", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, v := range tt.connection {
+			r.Header.Add("Connection", v)
+		}
+		if tt.upgrade != "" {
+			r.Header.Set("Upgrade", tt.upgrade)
+		}
+		if got := isUpgrade(r); got != tt.want {
+			t.Errorf("isUpgrade(Connection %q, Upgrade %q) = %v, want %v", tt.connection, tt.upgrade, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSE tuning. Every replica tails the outbox itself, so each one sees every
+// event and ids (the outbox seq) are the same on all of them.
+const (
+	sseReplaySize     = 1024
+	sseClientBuffer   = 64
+	sseHeartbeat      = 15 * time.Second
+	sseRetryMillis    = 3000
+	sseTailBatchSize  = 500
+	sseTailPoll       = time.Second
+	sseGapTimeout     = 30 * time.Second
+	sseEventReset     = "reset"
+	sseLastEventIDHdr = "Last-Event-ID"
+
+	// Custom field names behind the ?tag= and ?owner= shorthands.
+	tagsField  = "tags"
+	ownerField = "owner"
+)
+
+// hubEvent is an outbox event with its stream id and decoded contact,
+// decoded once so that filters do not re-parse it per client.
+type hubEvent struct {
+	seq     int64
+	evt     Event
+	contact Contact
+}
+
+// eventFilter narrows a stream. Contacts have no built-in tags or owner, so
+// those are custom fields: ?tag=vip is shorthand for cf.tags=vip and
+// ?owner=grace for cf.owner=grace. A custom field condition matches when the
+// value is equal or, for comma-separated values such as tag lists, when any
+// item is equal.
+type eventFilter struct {
+	types     []string
+	contactID int64
+	fields    map[string]string
+}
+
+type sseClient struct {
+	ch     chan hubEvent
+	filter eventFilter
+}
+
+// hub fans events out to connected SSE clients. publish never blocks: a
+// client whose buffer is full is disconnected and can resume with
+// Last-Event-ID from the replay buffer.
+type hub struct {
+	mu      sync.Mutex
+	replay  []hubEvent // ring, oldest first once full
+	start   int
+	floor   int64 // seq of the newest event no longer in replay
+	clients map[*sseClient]struct{}
+	wake    chan struct{}
+}
+
+var eventHub = &hub{
+	clients: make(map[*sseClient]struct{}),
+	wake:    make(chan struct{}, 1),
+}
+
+func (h *hub) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *hub) publish(e hubEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.replay) < sseReplaySize {
+		h.replay = append(h.replay, e)
+	} else {
+		h.floor = h.replay[h.start].seq
+		h.replay[h.start] = e
+		h.start = (h.start + 1) % sseReplaySize
+	}
+	for c := range h.clients {
+		if !c.filter.match(e) {
+			continue
+		}
+		select {
+		case c.ch <- e:
+		default:
+			// Too slow: drop it rather than block everyone else.
+			delete(h.clients, c)
+			close(c.ch)
+		}
+	}
+}
+
+// subscribe registers a client and returns the buffered events after
+// lastID that match its filter. truncated is true when events after lastID
+// may have been evicted from the replay buffer, i.e. lastID is older than
+// what it still holds. Gaps in seq are normal (rolled back inserts leave
+// them) and do not count.
+func (h *hub) subscribe(lastID int64, f eventFilter) (c *sseClient, replay []hubEvent, truncated bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c = &sseClient{ch: make(chan hubEvent, sseClientBuffer), filter: f}
+	h.clients[c] = struct{}{}
+	if lastID <= 0 {
+		return c, nil, false
+	}
+	truncated = lastID < h.floor
+	ordered := append(slices.Clone(h.replay[h.start:]), h.replay[:h.start]...)
+	for _, e := range ordered {
+		if e.seq > lastID && f.match(e) {
+			replay = append(replay, e)
+		}
+	}
+	return c, replay, truncated
+}
+
+func (h *hub) unsubscribe(c *sseClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+// runTail feeds the hub from the outbox until ctx is cancelled. On start it
+// preloads the replay buffer so clients can resume across restarts.
+func (h *hub) runTail(ctx context.Context) {
+	var last int64
+	headCtx, cancel := dbCtx(ctx)
+	if err := db.QueryRowContext(headCtx, `SELECT COALESCE(MAX(seq), 0) FROM event_outbox`).Scan(&last); err != nil {
+		slog.Error("sse: read outbox head", "err", err)
+	}
+	cancel()
+	after := max(0, last-sseReplaySize)
+	if preload, err := readOutbox(ctx, db, after, sseReplaySize); err == nil {
+		h.mu.Lock()
+		h.floor = after
+		h.mu.Unlock()
+		for _, e := range preload {
+			h.publish(toHubEvent(e))
+		}
+	} else {
+		slog.Error("sse: preload", "err", err)
+	}
+
+	t := time.NewTicker(sseTailPoll)
+	defer t.Stop()
+	for {
+		batch, err := readOutbox(ctx, db, last, sseTailBatchSize)
+		if err != nil {
+			slog.Error("sse: tail outbox", "err", err)
+		}
+		for _, e := range batch {
+			if !gapSettled(e, last, sseGapTimeout) {
+				break
+			}
+			h.publish(toHubEvent(e))
+			last = e.seq
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-h.wake:
+		}
+	}
+}
+
+func toHubEvent(e outboxEntry) hubEvent {
+	he := hubEvent{seq: e.seq, evt: e.evt}
+	if len(e.evt.Data) > 0 {
+		_ = json.Unmarshal(e.evt.Data, &he.contact)
+	}
+	return he
+}
+
+// streamContactEvents serves GET /contacts/events as Server-Sent Events.
+//
+// Query params: type=contact.created,... contactId=N tag=x owner=x cf.<field>=value.
+// Resume with the Last-Event-ID header (or ?lastEventId=); if the replay
+// buffer no longer reaches back that far a "reset" event is sent first and
+// the client should re-read GET /contacts.
+func streamContactEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	lastID := r.Header.Get(sseLastEventIDHdr)
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var after int64
+	if lastID != "" {
+		if after, err = strconv.ParseInt(lastID, 10, 64); err != nil || after < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID: %q", lastID))
+			return
+		}
+	}
+
+	version := apiVersionOf(r)
+	c, replay, truncated := eventHub.subscribe(after, filter)
+	defer eventHub.unsubscribe(c)
+
+	// The stream outlives the server's WriteTimeout; heartbeats detect dead clients.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	if truncated {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", sseEventReset)
+	}
+	for _, e := range replay {
+		writeSSE(w, version, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-c.ch:
+			if !ok {
+				return // dropped as a slow client; it will reconnect and resume
+			}
+			writeSSE(w, version, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, version string, e hubEvent) {
+	data, _ := json.Marshal(renderEvent(version, e.evt))
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.seq, e.evt.Type, data)
+}
+
+func parseEventFilter(q url.Values) (eventFilter, error) {
+	var f eventFilter
+	if s := q.Get("type"); s != "" {
+		for _, t := range strings.Split(s, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(contactEventTypes, t) {
+				return f, fmt.Errorf("invalid type %q", t)
+			}
+			f.types = append(f.types, t)
+		}
+	}
+	if s := q.Get("contactId"); s != "" {
+		id, err := parseIDParam(s)
+		if err != nil {
+			return f, err
+		}
+		f.contactID = id
+	}
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, customFieldFilterPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if f.fields == nil {
+			f.fields = make(map[string]string)
+		}
+		f.fields[name] = values[0]
+	}
+	for param, field := range map[string]string{"tag": tagsField, "owner": ownerField} {
+		if v := q.Get(param); v != "" {
+			if f.fields == nil {
+				f.fields = make(map[string]string)
+			}
+			f.fields[field] = v
+		}
+	}
+	return f, nil
+}
+
+func (f eventFilter) match(e hubEvent) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, e.evt.Type) {
+		return false
+	}
+	if f.contactID != 0 && e.evt.ContactID != f.contactID {
+		return false
+	}
+	for name, want := range f.fields {
+		v, ok := e.contact.CustomFields[name]
+		if !ok {
+			return false
+		}
+		got := formatFieldValue(v)
+		if got != want && !slices.Contains(splitTrim(got), want) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitTrim(s string) []string {
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
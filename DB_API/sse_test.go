@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func newTestHub(seqs ...int64) *hub {
+	h := &hub{clients: make(map[*sseClient]struct{}), wake: make(chan struct{}, 1)}
+	for _, seq := range seqs {
+		h.publish(hubEvent{seq: seq})
+	}
+	return h
+}
+
+func TestHubSubscribeTruncation(t *testing.T) {
+	full := make([]int64, sseReplaySize+10)
+	for i := range full {
+		full[i] = int64(i + 1)
+	}
+	for _, tt := range []struct {
+		name          string
+		seqs          []int64
+		lastID        int64
+		wantReplay    int
+		wantTruncated bool
+	}{
+		{"caught up", []int64{1, 2, 3}, 3, 0, false},
+		{"behind", []int64{1, 2, 3}, 1, 2, false},
+		// 3 and 4 were never written, e.g. rolled back inserts.
+		{"gap after lastID", []int64{1, 2, 5, 6}, 2, 2, false},
+		{"gap before the ring", []int64{7, 8}, 2, 2, false},
+		{"oldest evicted", full, 10, sseReplaySize, false},
+		{"evicted after lastID", full, 9, sseReplaySize, true},
+		{"from the start", full, 1, sseReplaySize, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(tt.seqs...)
+			_, replay, truncated := h.subscribe(tt.lastID, eventFilter{})
+			if len(replay) != tt.wantReplay || truncated != tt.wantTruncated {
+				t.Errorf("subscribe(%d) replayed %d events, truncated %v; want %d, %v", tt.lastID, len(replay), truncated, tt.wantReplay, tt.wantTruncated)
+			}
+			for _, e := range replay {
+				if e.seq <= tt.lastID {
+					t.Errorf("replayed seq %d, not after %d", e.seq, tt.lastID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The contact operations shared by the REST handlers, GraphQL resolvers and
+// gRPC service. Errors that callers should see as anything other than an
+// internal error are wrapped with statusErr.
+
+// contactQuery selects contacts for listing. The zero value matches every
+// contact, ordered by id.
+type contactQuery struct {
+	// Search is a substring match on first name, last name or email.
+	Search  string
+	Company *string
+	Email   *string
+	// Filters holds cf.<name>=value conditions as accepted by GET /contacts.
+	Filters url.Values
+	// SortColumn is one of contactSortColumns; empty means id.
+	SortColumn string
+	Descending bool
+}
+
+var contactSortColumns = []string{"id", "first_name", "last_name", "email", "company", "created_at", "updated_at"}
+
+func (q contactQuery) where(defs []CustomField) (string, []any, error) {
+	var conds []string
+	var args []any
+	if s := strings.TrimSpace(q.Search); s != "" {
+		pattern := "%" + s + "%"
+		like := dialect.like()
+		conds = append(conds, fmt.Sprintf("(first_name %[1]s ? OR last_name %[1]s ? OR email %[1]s ?)", like))
+		args = append(args, pattern, pattern, pattern)
+	}
+	if q.Company != nil {
+		conds = append(conds, dialect.equalFold("company"))
+		args = append(args, *q.Company)
+	}
+	if q.Email != nil {
+		conds = append(conds, dialect.equalFold("email"))
+		args = append(args, *q.Email)
+	}
+	cfWhere, cfArgs, err := customFieldFilters(defs, q.Filters)
+	if err != nil {
+		return "", nil, statusErr(http.StatusBadRequest, err)
+	}
+	if cfWhere != "" {
+		conds = append(conds, strings.TrimPrefix(cfWhere, "\nWHERE "))
+		args = append(args, cfArgs...)
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return "\nWHERE " + strings.Join(conds, "\n  AND "), args, nil
+}
+
+func (q contactQuery) orderBy() (string, error) {
+	col := q.SortColumn
+	if col == "" {
+		col = "id"
+	}
+	found := false
+	for _, c := range contactSortColumns {
+		found = found || c == col
+	}
+	if !found {
+		return "", statusErr(http.StatusBadRequest, fmt.Errorf("invalid sort column %q", col))
+	}
+	nullable := col == "company"
+	if q.Descending {
+		col += " DESC"
+	}
+	if nullable {
+		col += dialect.nullsFirst(q.Descending)
+	}
+	if col != "id" {
+		col += ", id"
+	}
+	return col, nil
+}
+
+// queryContacts returns up to limit contacts matching q after skipping offset,
+// with their custom field values, reading them from pool.
+func queryContacts(ctx context.Context, pool queryer, q contactQuery, limit, offset int) ([]Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	defs, err := loadCustomFields(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := q.where(defs)
+	if err != nil {
+		return nil, err
+	}
+	order, err := q.orderBy()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmts.query(ctx, pool, `
+SELECT `+contactColumns+`
+FROM contacts`+where+`
+ORDER BY `+order+`
+LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanContacts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachCustomValues(ctx, pool, defs, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// countContacts returns how many contacts match q, reading them from pool.
+func countContacts(ctx context.Context, pool queryer, q contactQuery) (int, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	defs, err := loadCustomFields(ctx, pool)
+	if err != nil {
+		return 0, err
+	}
+	where, args, err := q.where(defs)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	err = stmts.queryRow(ctx, pool, `SELECT COUNT(*) FROM contacts`+where, args...).Scan(&n)
+	return n, err
+}
+
+// insertContact validates and stores a new contact and records its
+// contact.created event.
+func insertContact(ctx context.Context, in ContactInput) (Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	if err := validateInput(in); err != nil {
+		return Contact{}, statusErr(http.StatusUnprocessableEntity, err)
+	}
+	defs, err := loadCustomFields(ctx, db)
+	if err != nil {
+		return Contact{}, err
+	}
+	custom, err := validateCustomValues(defs, in.CustomFields, true)
+	if err != nil {
+		return Contact{}, statusErr(http.StatusUnprocessableEntity, err)
+	}
+
+	// created_at and updated_at are handled by MySQL defaults, but we can set explicitly if desired
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Contact{}, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertID(ctx, tx, sqlInsertContact,
+		in.FirstName, in.LastName, nullable(in.Company), in.Email, nullable(in.Phone), now, now)
+	if err != nil {
+		if isUniqueEmailErr(err) {
+			return Contact{}, statusErr(http.StatusConflict, fmt.Errorf("email already exists"))
+		}
+		return Contact{}, err
+	}
+	if err := saveCustomValues(ctx, tx, id, defs, custom, false); err != nil {
+		return Contact{}, err
+	}
+
+	contact := Contact{
+		ID:        id,
+		FirstName: in.FirstName,
+		LastName:  in.LastName,
+		Company:   in.Company,
+		Email:     in.Email,
+		Phone:     in.Phone,
+		CreatedAt: now,
+		UpdatedAt: now,
+
+		CustomFields: decodeCustomValues(defs, custom),
+	}
+	if err := recordContactEvent(ctx, tx, eventContactCreated, id, contact); err != nil {
+		return Contact{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Contact{}, err
+	}
+	notifyEventsCommitted()
+	contactsCreated.Inc()
+	return contact, nil
+}
+
+// replaceContact overwrites every field of contact id, including its custom
+// field values.
+func replaceContact(ctx context.Context, id int64, in ContactInput) (Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	if err := validateInput(in); err != nil {
+		return Contact{}, statusErr(http.StatusUnprocessableEntity, err)
+	}
+	defs, err := loadCustomFields(ctx, db)
+	if err != nil {
+		return Contact{}, err
+	}
+	custom, err := validateCustomValues(defs, in.CustomFields, true)
+	if err != nil {
+		return Contact{}, statusErr(http.StatusUnprocessableEntity, err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Contact{}, err
+	}
+	defer tx.Rollback()
+
+	res, err := stmts.exec(ctx, tx, sqlReplaceContact,
+		in.FirstName, in.LastName, nullable(in.Company), in.Email, nullable(in.Phone), now, id)
+	if err != nil {
+		if isUniqueEmailErr(err) {
+			return Contact{}, statusErr(http.StatusConflict, fmt.Errorf("email already exists"))
+		}
+		return Contact{}, err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return Contact{}, statusErr(http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	}
+	if err := saveCustomValues(ctx, tx, id, defs, custom, true); err != nil {
+		return Contact{}, err
+	}
+	return commitContactUpdate(ctx, tx, id)
+}
+
+// applyContactPatch updates only the fields set in in.
+func applyContactPatch(ctx context.Context, id int64, in PartialContact) (Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	fields := make([]string, 0, 6)
+	args := make([]any, 0, 7)
+	if in.FirstName != nil {
+		if strings.TrimSpace(*in.FirstName) == "" {
+			return Contact{}, statusErr(http.StatusUnprocessableEntity, fmt.Errorf("firstName is required"))
+		}
+		fields = append(fields, "first_name = ?")
+		args = append(args, *in.FirstName)
+	}
+	if in.LastName != nil {
+		if strings.TrimSpace(*in.LastName) == "" {
+			return Contact{}, statusErr(http.StatusUnprocessableEntity, fmt.Errorf("lastName is required"))
+		}
+		fields = append(fields, "last_name = ?")
+		args = append(args, *in.LastName)
+	}
+	if in.Company != nil {
+		fields = append(fields, "company = ?")
+		args = append(args, nullable(in.Company))
+	}
+	if in.Email != nil {
+		if !emailRegex.MatchString(*in.Email) {
+			return Contact{}, statusErr(http.StatusUnprocessableEntity, fmt.Errorf("invalid email"))
+		}
+		fields = append(fields, "email = ?")
+		args = append(args, *in.Email)
+	}
+	if in.Phone != nil {
+		fields = append(fields, "phone = ?")
+		args = append(args, nullable(in.Phone))
+	}
+	var defs []CustomField
+	var custom map[string]*string
+	if in.CustomFields != nil {
+		var err error
+		if defs, err = loadCustomFields(ctx, db); err != nil {
+			return Contact{}, err
+		}
+		if custom, err = validateCustomValues(defs, in.CustomFields, false); err != nil {
+			return Contact{}, statusErr(http.StatusUnprocessableEntity, err)
+		}
+	}
+	fields = append(fields, "updated_at = ?")
+	now := time.Now().UTC().Truncate(time.Second)
+	args = append(args, now)
+	args = append(args, id)
+
+	if len(args) == 2 && len(custom) == 0 { // only updated_at + id
+		return Contact{}, statusErr(http.StatusBadRequest, fmt.Errorf("no updatable fields provided"))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Contact{}, err
+	}
+	defer tx.Rollback()
+
+	q := fmt.Sprintf("UPDATE contacts SET %s WHERE id = ?", strings.Join(fields, ", "))
+	res, err := stmts.exec(ctx, tx, q, args...)
+	if err != nil {
+		if isUniqueEmailErr(err) {
+			return Contact{}, statusErr(http.StatusConflict, fmt.Errorf("email already exists"))
+		}
+		return Contact{}, err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return Contact{}, statusErr(http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	}
+	if err := saveCustomValues(ctx, tx, id, defs, custom, false); err != nil {
+		return Contact{}, err
+	}
+	return commitContactUpdate(ctx, tx, id)
+}
+
+// commitContactUpdate reads the updated contact inside tx, records the
+// contact.updated event in the outbox and commits.
+func commitContactUpdate(ctx context.Context, tx *sql.Tx, id int64) (Contact, error) {
+	c, err := loadContact(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Contact{}, statusErr(http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	}
+	if err != nil {
+		return Contact{}, err
+	}
+	if err := recordContactEvent(ctx, tx, eventContactUpdated, id, c); err != nil {
+		return Contact{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Contact{}, err
+	}
+	notifyEventsCommitted()
+	contactsUpdated.Inc()
+	return c, nil
+}
+
+// removeContact deletes contact id and returns its last state.
+func removeContact(ctx context.Context, id int64) (Contact, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Contact{}, err
+	}
+	defer tx.Rollback()
+
+	// Keep the last state for the contact.deleted event so subscribers can filter on it.
+	last, err := loadContact(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Contact{}, statusErr(http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	}
+	if err != nil {
+		return Contact{}, err
+	}
+	res, err := stmts.exec(ctx, tx, sqlDeleteContact, id)
+	if err != nil {
+		return Contact{}, err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return Contact{}, statusErr(http.StatusNotFound, fmt.Errorf("contact %d not found", id))
+	}
+	if err := recordContactEvent(ctx, tx, eventContactDeleted, id, last); err != nil {
+		return Contact{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Contact{}, err
+	}
+	notifyEventsCommitted()
+	contactsDeleted.Inc()
+	return last, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"my-go-api/client"
+)
+
+// TestWritesUseOneConnection runs every write with a pool of one connection:
+// a write that asks the pool for another while its transaction holds the
+// only one waits out the query timeout.
+func TestWritesUseOneConnection(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DB.QueryTimeout = 2 * time.Second })
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		ada := createTestContact(t, h, "Ada", "ada@example.com")
+		grace := createTestContact(t, h, "Grace", "grace@example.com")
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.SetMaxOpenConns(cfg().DB.MaxOpenConns) })
+
+		path := fmt.Sprintf("/contacts/%d", ada)
+		for _, tt := range []struct {
+			method, path string
+			body         any
+			want         int
+		}{
+			{http.MethodPut, path, client.ContactInput{FirstName: "Ada", LastName: "King", Email: "ada@example.com"}, http.StatusOK},
+			{http.MethodPatch, path, client.ContactPatch{Phone: ptr("555-0100")}, http.StatusOK},
+			{http.MethodPost, path + "/merge", client.MergeInput{SourceId: grace}, http.StatusOK},
+			{http.MethodDelete, path, nil, http.StatusNoContent},
+		} {
+			start := time.Now()
+			if status := do(t, h, tt.method, tt.path, tt.body, nil); status != tt.want {
+				t.Errorf("%s %s: status %d after %s, want %d", tt.method, tt.path, status, time.Since(start).Round(time.Millisecond), tt.want)
+			}
+		}
+	})
+}
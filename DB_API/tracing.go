@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+
+	"github.com/XSAM/otelsql"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing follows the OpenTelemetry conventions: every HTTP request and gRPC
+// call gets a server span, continuing the trace named in an incoming W3C
+// traceparent header, and every SQL call made with the request's context is
+// a child span. tracing.exporter (OTEL_TRACES_EXPORTER) is "none" by default,
+// which keeps the ids but drops the spans; "otlp" sends spans to a collector
+// at tracing.endpoint over tracing.protocol, grpc or http/protobuf. The trace
+// id is returned in the Trace-Id header and in error bodies, and logged with
+// each request.
+const (
+	tracerName    = "my-go-api"
+	traceIDHeader = "Trace-Id"
+)
+
+var tracer = otel.Tracer(tracerName)
+
+// initTracing installs the W3C propagators and a tracer provider, which
+// exports to c.Endpoint unless the exporter is "none". Trace ids are assigned
+// either way, so logs and errors can be correlated without a collector. The
+// returned function flushes buffered spans; call it on shutdown.
+func initTracing(ctx context.Context, c TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(c.ServiceName))),
+	}
+	if c.Exporter != "none" {
+		var client otlptrace.Client
+		switch c.Protocol {
+		case "grpc":
+			client = otlptracegrpc.NewClient(otlptracegrpc.WithEndpointURL(c.endpoint()))
+		case "http/protobuf":
+			client = otlptracehttp.NewClient(otlptracehttp.WithEndpointURL(c.endpoint()))
+		}
+		exporter, err := otlptrace.New(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// openDB opens the pool for c.Driver with a span around each call. Calls
+// made outside any trace (no span in their context) are not recorded, so
+// background workers do not start a trace per poll.
+func openDB(c DBConfig) (*sql.DB, error) {
+	system := semconv.DBSystemNameMySQL
+	var connector driver.Connector
+	if c.Driver == "postgres" {
+		system = semconv.DBSystemNamePostgreSQL
+		var err error
+		if connector, err = newPGConnector(c.DSN); err != nil {
+			return nil, err
+		}
+	} else {
+		conf, err := mysql.ParseDSN(c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		if connector, err = mysql.NewConnector(conf); err != nil {
+			return nil, err
+		}
+	}
+	return otelsql.OpenDB(connector,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanFromContext(ctx).SpanContext().IsValid()
+			},
+		}),
+	), nil
+}
+
+// traceRequests starts the server span for a request. It is named after the
+// route pattern once the router has matched one, like the metrics.
+func traceRequests(mux *chi.Mux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			method := methodLabel(r.Method)
+			attrs := []attribute.KeyValue{
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			}
+			if method != r.Method {
+				attrs[0] = semconv.HTTPRequestMethodOther
+				attrs = append(attrs, semconv.HTTPRequestMethodOriginal(r.Method))
+			}
+			ctx, span := tracer.Start(ctx, method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...))
+			defer span.End()
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				w.Header().Set(traceIDHeader, sc.TraceID().String())
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			route := routePattern(mux, r)
+			span.SetName(method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+			status := servedStatus(ww, r)
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// traceID returns the id of the trace ctx belongs to, or "".
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
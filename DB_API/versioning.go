@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The contacts API is served under /v1/contacts and /v2/contacts. Plain
+// /contacts picks the version from the Accept header, either as a vendor
+// type (application/vnd.contacts.v2+json) or a parameter
+// (application/json; version=2), and is v1 otherwise so that existing
+// clients keep working. Request bodies are the same in every version; only
+// the representation of contacts in responses differs.
+const (
+	apiVersionHeader  = "API-Version"
+	defaultAPIVersion = "v1"
+	latestAPIVersion  = "v2"
+	vendorMediaPrefix = "application/vnd.contacts."
+)
+
+// apiVersions lists the versions being served. v1 was deprecated when v2
+// shipped, on api.v1Deprecated (API_V1_DEPRECATED); its sunset date is
+// api.v1Sunset (API_V1_SUNSET).
+var apiVersions = map[string]bool{"v1": true, "v2": true}
+
+type apiVersionKey struct{}
+
+// negotiateAPIVersion resolves the version of a request to the contacts
+// routes, rejects versions that are not served with 406 and announces
+// deprecation (RFC 9745) and sunset (RFC 8594) on old versions.
+func negotiateAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := resolveAPIVersion(r)
+		if err != nil {
+			writeError(w, http.StatusNotAcceptable, err)
+			return
+		}
+		h := w.Header()
+		h.Set(apiVersionHeader, version)
+		if _, pinned := versionFromPath(r.URL.Path); !pinned {
+			h.Add("Vary", "Accept")
+		}
+		if deprecated := cfg().deprecated(version); !deprecated.IsZero() {
+			h.Set("Deprecation", fmt.Sprintf("@%d", deprecated.Unix()))
+			if sunset := cfg().sunset(version); !sunset.IsZero() {
+				h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successorPath(r.URL.Path)))
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+	})
+}
+
+// resolveAPIVersion takes the version from the path prefix if there is one,
+// else from Accept. Asking for a different version in Accept than in the
+// path is an error rather than silently ignored.
+func resolveAPIVersion(r *http.Request) (string, error) {
+	requested := acceptedAPIVersion(r.Header.Get("Accept"))
+	version, pinned := versionFromPath(r.URL.Path)
+	switch {
+	case pinned && requested != "" && requested != version:
+		return "", fmt.Errorf("the Accept header asks for API version %s but the path is /%s", requested, version)
+	case !pinned && requested != "":
+		version = requested
+	case !pinned:
+		version = defaultAPIVersion
+	}
+	if _, ok := apiVersions[version]; !ok {
+		return "", fmt.Errorf("unsupported API version %q: use v1 or v2", version)
+	}
+	return version, nil
+}
+
+// versionFromPath reports the version prefix of paths like /v2/contacts.
+func versionFromPath(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, "/")
+	if !ok {
+		return "", false
+	}
+	seg, _, _ := strings.Cut(rest, "/")
+	if _, served := apiVersions[seg]; served {
+		return seg, true
+	}
+	return "", false
+}
+
+// unversionedPath strips a version prefix: /v2/contacts/1 -> /contacts/1.
+func unversionedPath(path string) string {
+	if v, ok := versionFromPath(path); ok {
+		return strings.TrimPrefix(path, "/"+v)
+	}
+	return path
+}
+
+func successorPath(path string) string {
+	return "/" + latestAPIVersion + unversionedPath(path)
+}
+
+// acceptedAPIVersion returns the first version named in an Accept header,
+// or "" if none is.
+func acceptedAPIVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(mt, vendorMediaPrefix); ok {
+			if v, _, _ := strings.Cut(rest, "+"); v != "" {
+				return v
+			}
+		}
+		if v := params["version"]; v != "" {
+			if !strings.HasPrefix(v, "v") {
+				v = "v" + v
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// apiVersionOf is the version negotiated for r, v1 outside the contacts routes.
+func apiVersionOf(r *http.Request) string {
+	if v, ok := r.Context().Value(apiVersionKey{}).(string); ok {
+		return v
+	}
+	return defaultAPIVersion
+}
+
+// ContactV2 is the v2 representation of a contact: email addresses and
+// phone numbers are lists, ready for contacts with more than one of each.
+type ContactV2 struct {
+	ID        int64          `json:"id"`
+	FirstName string         `json:"firstName"`
+	LastName  string         `json:"lastName"`
+	Company   *string        `json:"company,omitempty"`
+	Emails    []ContactEmail `json:"emails"`
+	Phones    []ContactPhone `json:"phones"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+
+	CustomFields map[string]any `json:"customFields,omitempty"`
+}
+
+type ContactEmail struct {
+	Address string `json:"address"`
+	Primary bool   `json:"primary"`
+}
+
+type ContactPhone struct {
+	Number  string `json:"number"`
+	Primary bool   `json:"primary"`
+}
+
+func contactV2(c Contact) ContactV2 {
+	v := ContactV2{
+		ID:           c.ID,
+		FirstName:    c.FirstName,
+		LastName:     c.LastName,
+		Company:      c.Company,
+		Emails:       []ContactEmail{{Address: c.Email, Primary: true}},
+		Phones:       []ContactPhone{},
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		CustomFields: c.CustomFields,
+	}
+	if c.Phone != nil {
+		v.Phones = append(v.Phones, ContactPhone{Number: *c.Phone, Primary: true})
+	}
+	return v
+}
+
+// The render functions are the response adapters: they return what a
+// handler should encode for the request's API version. v1 is the internal
+// model as is.
+
+func renderContact(r *http.Request, c Contact) any {
+	if apiVersionOf(r) == "v2" {
+		return contactV2(c)
+	}
+	return c
+}
+
+func renderContacts(r *http.Request, cs []Contact) any {
+	if apiVersionOf(r) != "v2" || cs == nil {
+		return cs
+	}
+	out := make([]ContactV2, len(cs))
+	for i, c := range cs {
+		out[i] = contactV2(c)
+	}
+	return out
+}
+
+// The v2 wrappers below shadow the Contact fields of the embedded v1 type.
+
+type duplicateCandidateV2 struct {
+	DuplicateCandidate
+	ContactA ContactV2 `json:"contactA"`
+	ContactB ContactV2 `json:"contactB"`
+}
+
+func renderDuplicates(r *http.Request, ds []DuplicateCandidate) any {
+	if apiVersionOf(r) != "v2" {
+		return ds
+	}
+	out := make([]duplicateCandidateV2, len(ds))
+	for i, d := range ds {
+		out[i] = duplicateCandidateV2{d, contactV2(d.ContactA), contactV2(d.ContactB)}
+	}
+	return out
+}
+
+type networkNodeV2 struct {
+	NetworkNode
+	Contact ContactV2 `json:"contact"`
+}
+
+type contactNetworkV2 struct {
+	ContactNetwork
+	Nodes []networkNodeV2 `json:"nodes"`
+}
+
+func renderNetwork(r *http.Request, n ContactNetwork) any {
+	if apiVersionOf(r) != "v2" {
+		return n
+	}
+	nodes := make([]networkNodeV2, len(n.Nodes))
+	for i, node := range n.Nodes {
+		nodes[i] = networkNodeV2{node, contactV2(node.Contact)}
+	}
+	return contactNetworkV2{n, nodes}
+}
+
+type contactMergeV2 struct {
+	ContactMerge
+	Source json.RawMessage `json:"source"`
+	Result json.RawMessage `json:"result"`
+}
+
+// renderMerge converts the contact snapshots stored with a merge. They were
+// written as v1 JSON; one that does not decode is passed through unchanged.
+func renderMerge(r *http.Request, m ContactMerge) any {
+	if apiVersionOf(r) != "v2" {
+		return m
+	}
+	return contactMergeV2{m, snapshotV2(m.Source), snapshotV2(m.Result)}
+}
+
+func renderMerges(r *http.Request, ms []ContactMerge) any {
+	if apiVersionOf(r) != "v2" {
+		return ms
+	}
+	out := make([]any, len(ms))
+	for i, m := range ms {
+		out[i] = renderMerge(r, m)
+	}
+	return out
+}
+
+func snapshotV2(raw json.RawMessage) json.RawMessage {
+	var c Contact
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return raw
+	}
+	b, err := json.Marshal(contactV2(c))
+	if err != nil {
+		return raw
+	}
+	return b
+}
+
+// renderEvent converts the contact an event carries for a stream opened on
+// version. Event.Data is stored as v1 JSON, the shape webhooks and outbox
+// sinks receive.
+func renderEvent(version string, e Event) Event {
+	if version == "v2" && len(e.Data) > 0 {
+		e.Data = snapshotV2(e.Data)
+	}
+	return e
+}
+
+// csvContactLists flattens the v2 lists into CSV cells.
+func csvContactLists(c ContactV2) (emails, phones string) {
+	es := make([]string, len(c.Emails))
+	for i, e := range c.Emails {
+		es[i] = e.Address
+	}
+	ps := make([]string, len(c.Phones))
+	for i, p := range c.Phones {
+		ps[i] = p.Number
+	}
+	return strings.Join(es, ";"), strings.Join(ps, ";")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestV1DeprecationConfig(t *testing.T) {
+	for _, tt := range []struct {
+		deprecated, sunset string
+		wantErr            string
+	}{
+		{"2026-10-19", "", ""},
+		{"2026-10-19", "2027-06-30", ""},
+		{"2026-10-19", "2026-10-19", ""},
+		{"", "", ""},
+		{"2026-10-19", "2026-10-18", "api.v1Sunset: must not be before api.v1Deprecated"},
+		{"", "2027-06-30", "api.v1Sunset: requires api.v1Deprecated"},
+		{"19 Oct 2026", "", "api.v1Deprecated: invalid date"},
+	} {
+		c := defaultConfig()
+		c.API = APIConfig{V1Deprecated: tt.deprecated, V1Sunset: tt.sunset}
+		err := c.validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("deprecated %q, sunset %q: error %v, want %q", tt.deprecated, tt.sunset, err, tt.wantErr)
+		}
+	}
+}
+
+func TestV1DeprecationHeaders(t *testing.T) {
+	h := negotiateAPIVersion(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	headers := func(path string) http.Header {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Header()
+	}
+	for _, tt := range []struct {
+		deprecated, sunset          string
+		wantDeprecation, wantSunset string
+	}{
+		{"2026-10-19", "", "@1792368000", ""},
+		{"2027-01-01", "2027-06-30", "@1798761600", "Wed, 30 Jun 2027 00:00:00 GMT"},
+		{"", "", "", ""},
+	} {
+		c := defaultConfig()
+		c.API = APIConfig{V1Deprecated: tt.deprecated, V1Sunset: tt.sunset}
+		if err := c.validate(); err != nil {
+			t.Fatal(err)
+		}
+		withConfig(t, func(cur *Config) { *cur = *c })
+		v1 := headers("/v1/contacts")
+		if v1.Get("Deprecation") != tt.wantDeprecation || v1.Get("Sunset") != tt.wantSunset {
+			t.Errorf("v1 with %+v: Deprecation %q, Sunset %q; want %q, %q", c.API, v1.Get("Deprecation"), v1.Get("Sunset"), tt.wantDeprecation, tt.wantSunset)
+		}
+		if v2 := headers("/v2/contacts"); v2.Get("Deprecation") != "" || v2.Get("Sunset") != "" {
+			t.Errorf("v2 marked deprecated: %v", v2)
+		}
+	}
+}
+
+func TestRenderEvent(t *testing.T) {
+	phone := "+1 555 0100"
+	created, err := newEvent(eventContactCreated, 1, Contact{ID: 1, FirstName: "Ada", Email: "ada@example.com", Phone: &phone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := string(created.Data)
+	for _, tt := range []struct {
+		version string
+		evt     Event
+		want    string // a field of the rendered data, or "" for no data
+	}{
+		{"v1", created, `"email"`},
+		{"v2", created, `"emails"`},
+		{"v2", Event{ID: "x", Type: eventContactDeleted, ContactID: 1}, ""},
+	} {
+		sse := httptest.NewRecorder()
+		writeSSE(sse, tt.version, hubEvent{seq: 7, evt: tt.evt})
+		_, data, ok := strings.Cut(sse.Body.String(), "data: ")
+		if !ok {
+			t.Fatalf("%s: no data line in %q", tt.version, sse.Body.String())
+		}
+		var got Event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &got); err != nil {
+			t.Fatalf("%s: %v", tt.version, err)
+		}
+		switch {
+		case tt.want == "" && len(got.Data) != 0:
+			t.Errorf("%s: data %s, want none", tt.version, got.Data)
+		case tt.want != "" && !strings.Contains(string(got.Data), tt.want):
+			t.Errorf("%s: data %s, want a %s field", tt.version, got.Data, tt.want)
+		}
+	}
+	if string(created.Data) != stored {
+		t.Errorf("rendering changed the stored event: %s", created.Data)
+	}
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Delivery states. A delivery stays pending across retries and moves to dead
+// once it has used up its attempts; dead deliveries form the dead-letter list.
+const (
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryDead      = "dead"
+)
+
+// Signature headers sent with every delivery. Receivers verify with
+// HMAC-SHA256(secret, "<timestamp>.<body>") and compare against the hex
+// digest after the "sha256=" prefix.
+const (
+	headerWebhookEvent     = "X-Webhook-Event"
+	headerWebhookID        = "X-Webhook-Delivery"
+	headerWebhookTimestamp = "X-Webhook-Timestamp"
+	headerWebhookSignature = "X-Webhook-Signature"
+)
+
+// WebhookSubscription registers a URL to receive contact lifecycle events.
+// Secret is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookSubscriptionInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookDelivery is one attempt series to deliver one event to one subscription.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscriptionId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode *int            `json:"lastStatusCode,omitempty"`
+	LastError      *string         `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+const webhookSubscriptionsDDL = `
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  url        VARCHAR(2048) NOT NULL,
+  events     VARCHAR(255)  NOT NULL,
+  secret     VARCHAR(255)  NOT NULL,
+  active     BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+const webhookDeliveriesDDL = `
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  subscription_id  BIGINT NOT NULL,
+  event_id         VARCHAR(64) NOT NULL,
+  event_type       VARCHAR(64) NOT NULL,
+  payload          MEDIUMTEXT  NOT NULL,
+  status           VARCHAR(16) NOT NULL,
+  attempts         INT NOT NULL DEFAULT 0,
+  last_status_code INT NULL,
+  last_error       TEXT NULL,
+  next_attempt_at  TIMESTAMP(3) NOT NULL,
+  delivered_at     TIMESTAMP NULL,
+  created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_subscription_event (subscription_id, event_id),
+  INDEX idx_due (status, next_attempt_at),
+  FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, delivered_at, created_at, updated_at, payload`
+
+// webhookDispatcher persists deliveries and sends them with retries and
+// exponential backoff. Client and the backoff settings can be swapped out,
+// e.g. to point at an httptest server with millisecond backoff.
+type webhookDispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed delivery is hidden from other dispatch
+	// loops while its request is in flight.
+	Lease time.Duration
+
+	wake chan struct{}
+}
+
+var webhooks = newWebhookDispatcher(&http.Client{Timeout: 10 * time.Second, Transport: webhookTransport()})
+
+func newWebhookDispatcher(client *http.Client) *webhookDispatcher {
+	return &webhookDispatcher{
+		Client:      client,
+		MaxAttempts: 8,
+		BaseBackoff: 2 * time.Second,
+		MaxBackoff:  time.Hour,
+		Lease:       time.Minute,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// webhookTransport checks every address it connects to, after DNS, so that a
+// subscriber's host cannot be re-pointed at an internal address once the
+// subscription has been accepted, nor reach one through a redirect.
+func webhookTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	d := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	d.Control = func(_, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		if !cfg().Outbox.WebhookAllowPrivate && internalAddr(ap.Addr()) {
+			return fmt.Errorf("refusing to connect to internal address %s", ap.Addr())
+		}
+		return nil
+	}
+	t.DialContext = d.DialContext
+	return t
+}
+
+// internalAddr reports loopback, private (RFC 1918, fc00::/7), link-local
+// (which includes cloud metadata at 169.254.169.254), unspecified and
+// multicast addresses.
+func internalAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// checkWebhookHost refuses a subscription host that is, or resolves to, an
+// internal address unless outbox.webhookAllowPrivate is set. A host that
+// does not resolve yet is accepted; the transport checks again on every
+// delivery.
+func checkWebhookHost(ctx context.Context, host string) error {
+	if cfg().Outbox.WebhookAllowPrivate {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if internalAddr(ip) {
+			return fmt.Errorf("%s is an internal address", host)
+		}
+		return nil
+	}
+	if name := strings.ToLower(strings.TrimSuffix(host, ".")); name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return fmt.Errorf("%s is an internal address", host)
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if internalAddr(ip) {
+			return fmt.Errorf("%s resolves to internal address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+// Handlers
+
+func listWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `SELECT id, url, events, active, created_at FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []WebhookSubscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	s, err := scanSubscription(db.QueryRowContext(ctx, `SELECT id, url, events, active, created_at FROM webhook_subscriptions WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("webhook %d not found", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// createWebhook registers a subscription. If no secret is supplied one is
+// generated; either way it is echoed back only in this response.
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	var in WebhookSubscriptionInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("url must be an http(s) URL"))
+		return
+	}
+	if err := checkWebhookHost(r.Context(), u.Hostname()); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("url: %w", err))
+		return
+	}
+	if len(in.Events) == 0 {
+		in.Events = contactEventTypes
+	}
+	for _, e := range in.Events {
+		if !slices.Contains(contactEventTypes, e) {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("invalid event %q: must be one of %s", e, strings.Join(contactEventTypes, ", ")))
+			return
+		}
+	}
+	if in.Secret == "" {
+		in.Secret = newEventID() + newEventID()
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	id, err := insertID(ctx, db, `
+INSERT INTO webhook_subscriptions (url, events, secret, active, created_at)
+VALUES (?, ?, ?, TRUE, ?)`, u.String(), strings.Join(in.Events, ","), in.Secret, now)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, WebhookSubscription{
+		ID:        id,
+		URL:       u.String(),
+		Events:    in.Events,
+		Secret:    in.Secret,
+		Active:    true,
+		CreatedAt: now,
+	})
+}
+
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("webhook %d not found", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries is the delivery log for one subscription, newest first.
+func listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	where := "subscription_id = ?"
+	args := []any{id}
+	if s := r.URL.Query().Get("status"); s != "" {
+		where += " AND status = ?"
+		args = append(args, s)
+	}
+	writeDeliveries(w, r, where, args)
+}
+
+// listDeadLetters returns deliveries that exhausted their retries.
+func listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	writeDeliveries(w, r, "status = ?", []any{deliveryDead})
+}
+
+// retryDelivery puts a delivery (typically a dead letter) back in the queue
+// with a fresh set of attempts.
+func retryDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	res, err := db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET status = ?, attempts = 0, next_attempt_at = ?
+WHERE id = ? AND status <> ?`, deliveryPending, time.Now().UTC(), id, deliverySucceeded)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("retryable delivery %d not found", id))
+		return
+	}
+	webhooks.notify()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeDeliveries(w http.ResponseWriter, r *http.Request, where string, args []any) {
+	page := parseIntDefault(r.URL.Query().Get("page"), 1)
+	pageSize := pageSizeOrDefault(parseIntDefault(r.URL.Query().Get("pageSize"), 0))
+	if page < 1 {
+		page = 1
+	}
+	ctx, cancel := dbCtx(r.Context())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT `+deliveryColumns+`
+FROM webhook_deliveries
+WHERE `+where+`
+ORDER BY id DESC
+LIMIT ? OFFSET ?`, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var code sql.NullInt64
+		var lastErr sql.NullString
+		var delivered sql.NullTime
+		var payload string
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&code, &lastErr, &d.NextAttemptAt, &delivered, &d.CreatedAt, &d.UpdatedAt, &payload); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if code.Valid {
+			c := int(code.Int64)
+			d.LastStatusCode = &c
+		}
+		if lastErr.Valid {
+			d.LastError = &lastErr.String
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		d.Payload = json.RawMessage(payload)
+		items = append(items, d)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    items,
+	})
+}
+
+// Dispatch
+
+// enqueue records a pending delivery of evt for every active subscription
+// that wants its type, then wakes the dispatch loop.
+func (d *webhookDispatcher) enqueue(ctx context.Context, evt Event) error {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `SELECT id, events FROM webhook_subscriptions WHERE active`)
+	if err != nil {
+		return err
+	}
+	var targets []int64
+	for rows.Next() {
+		var id int64
+		var events string
+		if err := rows.Scan(&id, &events); err != nil {
+			rows.Close()
+			return err
+		}
+		if slices.Contains(strings.Split(events, ","), evt.Type) {
+			targets = append(targets, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, subID := range targets {
+		if _, err := db.ExecContext(ctx, dialect.insertIgnore(`
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			subID, evt.ID, evt.Type, string(payload), deliveryPending, now, now, now); err != nil {
+			return err
+		}
+	}
+	d.notify()
+	return nil
+}
+
+func (d *webhookDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers due webhooks until ctx is cancelled. It wakes on enqueue and
+// otherwise polls every second so retries fire close to their schedule.
+func (d *webhookDispatcher) run(ctx context.Context) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-d.wake:
+		}
+	}
+}
+
+type pendingDelivery struct {
+	id       int64
+	eventID  string
+	typ      string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+	due      time.Time
+}
+
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	now := time.Now().UTC()
+	due, err := loadDueDeliveries(ctx, now)
+	if err != nil {
+		slog.Error("webhooks: load due", "err", err)
+		return
+	}
+	for _, p := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		claimed, err := d.claim(ctx, p, now)
+		if err != nil {
+			slog.Error("webhooks: claim", "delivery_id", p.id, "err", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		code, err := d.send(ctx, p)
+		d.record(ctx, p, code, err)
+	}
+}
+
+// loadDueDeliveries returns up to 50 pending deliveries due by now.
+func loadDueDeliveries(ctx context.Context, now time.Time) ([]pendingDelivery, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+SELECT d.id, d.event_id, d.event_type, d.payload, d.attempts, d.next_attempt_at, s.url, s.secret
+FROM webhook_deliveries d
+JOIN webhook_subscriptions s ON s.id = d.subscription_id
+WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active
+ORDER BY d.id
+LIMIT 50`, deliveryPending, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var due []pendingDelivery
+	for rows.Next() {
+		var p pendingDelivery
+		var payload string
+		if err := rows.Scan(&p.id, &p.eventID, &p.typ, &payload, &p.attempts, &p.due, &p.url, &p.secret); err != nil {
+			return nil, err
+		}
+		p.payload = []byte(payload)
+		due = append(due, p)
+	}
+	return due, rows.Err()
+}
+
+// claim pushes next_attempt_at out by the lease; only one loop wins.
+func (d *webhookDispatcher) claim(ctx context.Context, p pendingDelivery, now time.Time) (bool, error) {
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	res, err := db.ExecContext(ctx, `
+UPDATE webhook_deliveries SET next_attempt_at = ?
+WHERE id = ? AND status = ? AND next_attempt_at = ?`,
+		now.Add(d.Lease), p.id, deliveryPending, p.due)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// send POSTs the event payload with its HMAC signature and returns the
+// response status code.
+func (d *webhookDispatcher) send(ctx context.Context, p pendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerWebhookEvent, p.typ)
+	req.Header.Set(headerWebhookID, p.eventID)
+	req.Header.Set(headerWebhookTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(headerWebhookSignature, signWebhookPayload(p.secret, ts, p.payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of one attempt and schedules the next one.
+func (d *webhookDispatcher) record(ctx context.Context, p pendingDelivery, code int, sendErr error) {
+	now := time.Now().UTC()
+	attempts := p.attempts + 1
+	var statusCode any
+	if code != 0 {
+		statusCode = code
+	}
+
+	ctx, cancel := dbCtx(ctx)
+	defer cancel()
+	var err error
+	switch {
+	case sendErr == nil:
+		webhookDeliveries.WithLabelValues("succeeded").Inc()
+		_, err = db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET status = ?, attempts = ?, last_status_code = ?, last_error = NULL, delivered_at = ?
+WHERE id = ?`, deliverySucceeded, attempts, statusCode, now, p.id)
+	case attempts >= d.MaxAttempts:
+		slog.Warn("webhooks: delivery dead", "delivery_id", p.id, "attempts", attempts, "err", sendErr)
+		webhookDeliveries.WithLabelValues("dead").Inc()
+		_, err = db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET status = ?, attempts = ?, last_status_code = ?, last_error = ?
+WHERE id = ?`, deliveryDead, attempts, statusCode, sendErr.Error(), p.id)
+	default:
+		webhookDeliveries.WithLabelValues("retrying").Inc()
+		_, err = db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?
+WHERE id = ?`, attempts, statusCode, sendErr.Error(), now.Add(d.backoff(attempts)), p.id)
+	}
+	if err != nil {
+		slog.Error("webhooks: record delivery", "delivery_id", p.id, "err", err)
+	}
+}
+
+// backoff returns the delay before retry number attempt (1-based):
+// BaseBackoff * 2^(attempt-1), capped at MaxBackoff, plus up to 20% jitter.
+func (d *webhookDispatcher) backoff(attempt int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempt && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.MaxBackoff)
+	if delay > 0 {
+		delay += time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	}
+	return delay
+}
+
+// signWebhookPayload returns the X-Webhook-Signature value for body.
+func signWebhookPayload(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func scanSubscription(row rowScanner) (WebhookSubscription, error) {
+	var s WebhookSubscription
+	var events string
+	if err := row.Scan(&s.ID, &s.URL, &events, &s.Active, &s.CreatedAt); err != nil {
+		return s, err
+	}
+	s.Events = strings.Split(events, ",")
+	return s, nil
+}
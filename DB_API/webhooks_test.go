@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver is a subscriber that answers each delivery with the next
+// of statuses, repeating the last, and keeps what it was sent.
+type webhookReceiver struct {
+	*httptest.Server
+	statuses []int
+
+	mu       sync.Mutex
+	received []receivedWebhook
+}
+
+type receivedWebhook struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	rcv := &webhookReceiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		n := len(rcv.received)
+		rcv.received = append(rcv.received, receivedWebhook{at: time.Now(), header: r.Header.Clone(), body: body})
+		rcv.mu.Unlock()
+		w.WriteHeader(rcv.statuses[min(n, len(rcv.statuses)-1)])
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *webhookReceiver) requests() []receivedWebhook {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]receivedWebhook(nil), rcv.received...)
+}
+
+// testDispatcher returns a dispatcher for rcv with millisecond backoff.
+func testDispatcher(rcv *webhookReceiver, maxAttempts int) *webhookDispatcher {
+	d := newWebhookDispatcher(rcv.Client())
+	d.MaxAttempts = maxAttempts
+	d.BaseBackoff = 20 * time.Millisecond
+	d.MaxBackoff = time.Second
+	return d
+}
+
+// subscribe registers rcv for contact.created and enqueues one such event.
+// rcv listens on loopback, so private addresses are allowed for the test.
+func subscribe(t *testing.T, h http.Handler, d *webhookDispatcher, rcv *webhookReceiver) (WebhookSubscription, Event) {
+	t.Helper()
+	withConfig(t, func(c *Config) { c.Outbox.WebhookAllowPrivate = true })
+	var sub WebhookSubscription
+	in := WebhookSubscriptionInput{URL: rcv.URL, Events: []string{eventContactCreated}, Secret: "s3cret"}
+	if status := do(t, h, http.MethodPost, "/webhooks", in, &sub); status != http.StatusCreated {
+		t.Fatalf("create webhook: status %d", status)
+	}
+	evt, err := newEvent(eventContactCreated, 1, map[string]string{"firstName": "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.enqueue(context.Background(), evt); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	return sub, evt
+}
+
+// deliverUntil runs d until the subscription's delivery has left pending.
+func deliverUntil(t *testing.T, h http.Handler, d *webhookDispatcher, subID int64) WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.deliverDue(context.Background())
+		var page struct{ Items []WebhookDelivery }
+		do(t, h, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", subID), nil, &page)
+		if len(page.Items) != 1 {
+			t.Fatalf("deliveries = %+v, want one", page.Items)
+		}
+		if page.Items[0].Status != deliveryPending {
+			return page.Items[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery still pending after %d attempts", page.Items[0].Attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookDeliverySignature(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		rcv := newWebhookReceiver(t, http.StatusNoContent)
+		d := testDispatcher(rcv, 3)
+		sub, evt := subscribe(t, h, d, rcv)
+
+		got := deliverUntil(t, h, d, sub.ID)
+		if got.Status != deliverySucceeded || got.Attempts != 1 || got.LastStatusCode == nil || *got.LastStatusCode != http.StatusNoContent || got.DeliveredAt == nil {
+			t.Errorf("delivery = %+v, want succeeded on the first attempt with 204", got)
+		}
+		reqs := rcv.requests()
+		if len(reqs) != 1 {
+			t.Fatalf("receiver got %d requests, want 1", len(reqs))
+		}
+		hdr := reqs[0].header
+		if hdr.Get(headerWebhookEvent) != eventContactCreated || hdr.Get(headerWebhookID) != evt.ID {
+			t.Errorf("event headers = %q, %q, want %q, %q", hdr.Get(headerWebhookEvent), hdr.Get(headerWebhookID), eventContactCreated, evt.ID)
+		}
+		ts, err := strconv.ParseInt(hdr.Get(headerWebhookTimestamp), 10, 64)
+		if err != nil {
+			t.Fatalf("timestamp header: %v", err)
+		}
+		if want := signWebhookPayload("s3cret", ts, reqs[0].body); hdr.Get(headerWebhookSignature) != want {
+			t.Errorf("signature = %q, want %q", hdr.Get(headerWebhookSignature), want)
+		}
+		if bad := signWebhookPayload("other", ts, reqs[0].body); hdr.Get(headerWebhookSignature) == bad {
+			t.Error("signature does not depend on the secret")
+		}
+		if string(got.Payload) != string(reqs[0].body) {
+			t.Errorf("logged payload %s, sent %s", got.Payload, reqs[0].body)
+		}
+	})
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		rcv := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+		d := testDispatcher(rcv, 5)
+		sub, _ := subscribe(t, h, d, rcv)
+
+		got := deliverUntil(t, h, d, sub.ID)
+		if got.Status != deliverySucceeded || got.Attempts != 3 || *got.LastStatusCode != http.StatusOK || got.LastError != nil {
+			t.Errorf("delivery = %+v, want succeeded on the third attempt", got)
+		}
+		reqs := rcv.requests()
+		if len(reqs) != 3 {
+			t.Fatalf("receiver got %d requests, want 3", len(reqs))
+		}
+		// Retry n waits at least BaseBackoff * 2^(n-1).
+		for i, wait := range []time.Duration{d.BaseBackoff, 2 * d.BaseBackoff} {
+			if gap := reqs[i+1].at.Sub(reqs[i].at); gap < wait {
+				t.Errorf("retry %d came %s after the previous attempt, want at least %s", i+1, gap, wait)
+			}
+		}
+		if reqs[0].header.Get(headerWebhookID) != reqs[2].header.Get(headerWebhookID) {
+			t.Error("retries changed X-Webhook-Delivery")
+		}
+	})
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		rcv := newWebhookReceiver(t, http.StatusBadGateway)
+		d := testDispatcher(rcv, 3)
+		sub, _ := subscribe(t, h, d, rcv)
+
+		got := deliverUntil(t, h, d, sub.ID)
+		if got.Status != deliveryDead || got.Attempts != 3 || *got.LastStatusCode != http.StatusBadGateway || got.LastError == nil || got.DeliveredAt != nil {
+			t.Errorf("delivery = %+v, want dead after 3 attempts with 502", got)
+		}
+		if n := len(rcv.requests()); n != 3 {
+			t.Errorf("receiver got %d requests, want 3", n)
+		}
+		d.deliverDue(context.Background())
+		if n := len(rcv.requests()); n != 3 {
+			t.Errorf("dead delivery was sent again: %d requests", n)
+		}
+
+		var dead struct{ Items []WebhookDelivery }
+		do(t, h, http.MethodGet, "/webhooks/dead-letters", nil, &dead)
+		if len(dead.Items) != 1 || dead.Items[0].ID != got.ID {
+			t.Fatalf("dead letters = %+v, want delivery %d", dead.Items, got.ID)
+		}
+		var pending struct{ Items []WebhookDelivery }
+		do(t, h, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries?status=pending", sub.ID), nil, &pending)
+		if len(pending.Items) != 0 {
+			t.Errorf("pending deliveries = %+v, want none", pending.Items)
+		}
+
+		// A manual retry starts the attempts over.
+		if status := do(t, h, http.MethodPost, fmt.Sprintf("/webhooks/deliveries/%d/retry", got.ID), nil, nil); status != http.StatusAccepted {
+			t.Fatalf("retry: status %d", status)
+		}
+		do(t, h, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries?status=pending", sub.ID), nil, &pending)
+		if len(pending.Items) != 1 || pending.Items[0].Attempts != 0 {
+			t.Errorf("pending deliveries after retry = %+v, want the delivery with no attempts", pending.Items)
+		}
+	})
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := newWebhookDispatcher(nil)
+	d.BaseBackoff, d.MaxBackoff = time.Second, 10*time.Second
+	for _, tt := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	} {
+		for range 20 {
+			if got := d.backoff(tt.attempt); got < tt.want || got > tt.want+tt.want/5 {
+				t.Errorf("backoff(%d) = %s, want %s plus at most 20%%", tt.attempt, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	for _, tt := range []struct {
+		host     string
+		internal bool
+	}{
+		{"169.254.169.254", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"0.0.0.0", true},
+		{"localhost", true},
+		{"LOCALHOST.", true},
+		{"api.localhost", true},
+		{"93.184.215.14", false},
+		{"2606:4700::1111", false},
+		{"172.32.0.1", false},
+	} {
+		err := checkWebhookHost(context.Background(), tt.host)
+		if (err != nil) != tt.internal {
+			t.Errorf("checkWebhookHost(%q) = %v, want internal %v", tt.host, err, tt.internal)
+		}
+	}
+	withConfig(t, func(c *Config) { c.Outbox.WebhookAllowPrivate = true })
+	if err := checkWebhookHost(context.Background(), "169.254.169.254"); err != nil {
+		t.Errorf("with webhookAllowPrivate: %v", err)
+	}
+}
+
+func TestCreateWebhookRejectsInternalURL(t *testing.T) {
+	withTestDB(t, func(t *testing.T) {
+		h := testRouter()
+		for _, u := range []string{"http://169.254.169.254/latest/meta-data/", "http://localhost:9000/hooks", "https://10.0.0.5/hooks", "http://[::1]:8080/"} {
+			in := WebhookSubscriptionInput{URL: u}
+			if status := do(t, h, http.MethodPost, "/webhooks", in, nil); status != http.StatusUnprocessableEntity {
+				t.Errorf("create webhook for %s: status %d, want %d", u, status, http.StatusUnprocessableEntity)
+			}
+		}
+	})
+}
+
+// TestWebhookTransportRefusesInternal covers a host that passed the check
+// when subscribing and resolves to an internal address later.
+func TestWebhookTransportRefusesInternal(t *testing.T) {
+	rcv := newWebhookReceiver(t, http.StatusOK)
+	client := &http.Client{Transport: webhookTransport()}
+	if resp, err := client.Get(rcv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("GET %s succeeded, want the loopback address refused", rcv.URL)
+	}
+	withConfig(t, func(c *Config) { c.Outbox.WebhookAllowPrivate = true })
+	resp, err := client.Get(rcv.URL)
+	if err != nil {
+		t.Fatalf("with webhookAllowPrivate: %v", err)
+	}
+	resp.Body.Close()
+	if n := len(rcv.requests()); n != 1 {
+		t.Errorf("receiver got %d requests, want 1", n)
+	}
+}